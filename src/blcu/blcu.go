@@ -1,7 +1,15 @@
 package blcu
 
 import (
+	"context"
+	"errors"
 	"net"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/blcu/firmware"
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/HyperloopUPV-H8/Backend-H8/common/observable"
+	"github.com/pin/tftp/v3"
 
 	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
 	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
@@ -15,23 +23,39 @@ type BLCU struct {
 	ackChannel chan struct{}
 
 	sendOrder func(models.Order) error
+	firmware  *firmware.Store
+	queue     *jobQueue
+	downloads *downloadCache
+	progress  observable.ReplayObservable[*TransferProgress]
 
-	config BLCUConfig
+	config      BLCUConfig
+	ackTimeout  time.Duration
+	tftpTimeout time.Duration
 
 	trace zerolog.Logger
 }
 
 func NewBLCU(laddr net.TCPAddr, boardIds map[string]uint16, config BLCUConfig) BLCU {
 	trace.Info().Str("addr", laddr.String()).Msg("New BLCU")
+	blcuTrace := trace.With().Str("component", BLCU_COMPONENT_NAME).Logger()
 
-	return BLCU{
-		addr:       laddr,
-		boardToId:  boardIds,
-		ackChannel: make(chan struct{}, BLCU_ACK_CHAN_BUF),
-		trace:      trace.With().Str("component", BLCU_COMPONENT_NAME).Logger(),
-		config:     config,
-		sendOrder:  func(o models.Order) error { return nil },
+	blcu := BLCU{
+		addr:        laddr,
+		boardToId:   boardIds,
+		ackChannel:  make(chan struct{}, BLCU_ACK_CHAN_BUF),
+		trace:       blcuTrace,
+		config:      config,
+		ackTimeout:  common.ParseDuration(config.AckTimeout, DEFAULT_ACK_TIMEOUT, blcuTrace),
+		tftpTimeout: common.ParseDuration(config.TFTPTimeout, DEFAULT_TFTP_TIMEOUT, blcuTrace),
+		sendOrder:   func(o models.Order) error { return nil },
+		progress:    observable.NewReplayObservable[*TransferProgress](nil),
 	}
+
+	blcu.queue = newJobQueue()
+	blcu.downloads = newDownloadCache()
+	go blcu.queue.run()
+
+	return blcu
 }
 
 func (blcu *BLCU) HandlerName() string {
@@ -42,26 +66,61 @@ func (blcu *BLCU) SetSendOrder(sendOrder func(o models.Order) error) {
 	blcu.sendOrder = sendOrder
 }
 
+func (blcu *BLCU) SetFirmwareStore(store *firmware.Store) {
+	blcu.firmware = store
+}
+
 func (blcu *BLCU) UpdateMessage(client wsModels.Client, msg wsModels.Message) {
 	blcu.trace.Debug().Str("topic", msg.Topic).Str("client", client.Id()).Msg("Update message")
+	if client.IsReadOnly() {
+		blcu.trace.Warn().Str("client", client.Id()).Msg("read only client tried to use the BLCU")
+		return
+	}
+
 	switch msg.Topic {
 	case blcu.config.Topics.Upload:
-		err := blcu.upload(client, msg.Payload)
-		if err != nil {
-			blcu.notifyUploadFailure(client)
-		} else {
-			blcu.notifyUploadSuccess(client)
-		}
-
+		blcu.enqueueUpload(client, msg.Payload)
 	case blcu.config.Topics.Download:
-		board, data, err := blcu.download(client, msg.Payload)
-		if err != nil {
-			blcu.notifyDownloadFailure(client)
-		} else {
-			blcu.notifyDownloadSuccess(client, data)
-			blcu.writeDownloadFile(board, data)
-		}
+		blcu.enqueueDownload(client, msg.Payload)
+	case blcu.config.Topics.Cancel:
+		blcu.cancelJob(client, msg.Payload)
+	case blcu.config.Topics.Progress:
+		observable.HandleSubscribe[*TransferProgress](&blcu.progress, msg, client)
+	}
+}
+
+// waitAck discards acks left over from previous requests before sending the order and waiting for its ack
+func (blcu *BLCU) waitAck(ctx context.Context, order models.Order) error {
+	for len(blcu.ackChannel) > 0 {
+		<-blcu.ackChannel
 	}
+
+	if err := blcu.sendOrder(order); err != nil {
+		return err
+	}
+
+	select {
+	case <-blcu.ackChannel:
+		return nil
+	case <-time.After(blcu.ackTimeout):
+		return errors.New("timeout")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (blcu *BLCU) newTFTPClient() (*tftp.Client, error) {
+	client, err := tftp.NewClient(blcu.addr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	client.SetTimeout(blcu.tftpTimeout)
+	if blcu.config.TFTPRetries > 0 {
+		client.SetRetries(blcu.config.TFTPRetries)
+	}
+
+	return client, nil
 }
 
 func (blcu *BLCU) NotifyAck() {
@@ -0,0 +1,177 @@
+package blcu_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/blcu"
+	"github.com/HyperloopUPV-H8/Backend-H8/blcu/blcutest"
+)
+
+var boardIds = map[string]uint16{"VCU": 1, "BMSL": 2}
+
+func newTestBLCU(t *testing.T, configure func(config *blcu.BLCUConfig)) (*blcu.BLCU, *blcutest.Server) {
+	t.Helper()
+
+	config := blcu.BLCUConfig{
+		DownloadPath: t.TempDir(),
+		AckTimeout:   "200ms",
+		TFTPTimeout:  "200ms",
+		TFTPRetries:  1,
+	}
+	config.Packets.Upload = blcu.PacketData{Id: 700, Field: "write_board"}
+	config.Packets.Download = blcu.PacketData{Id: 701, Field: "read_board"}
+	configure(&config)
+
+	server, err := blcutest.NewServer(boardIds, config)
+	if err != nil {
+		t.Fatalf("starting test server: %s", err)
+	}
+	t.Cleanup(server.Close)
+
+	board := blcu.NewBLCU(server.Addr(), boardIds, config)
+	board.SetSendOrder(server.SendOrder)
+	server.SetOnAck(board.NotifyAck)
+
+	return &board, server
+}
+
+func upload(t *testing.T, board *blcu.BLCU, target string, image []byte) (int, blcu.UploadReport) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	form.WriteField("board", target)
+	file, _ := form.CreateFormFile("file", "image.bin")
+	file.Write(image)
+	form.Close()
+
+	request := httptest.NewRequest(http.MethodPost, "/blcu/upload", body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	board.Handlers("/blcu")["/blcu/upload"].ServeHTTP(recorder, request)
+
+	var report blcu.UploadReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decoding report: %s", err)
+	}
+
+	return recorder.Code, report
+}
+
+func download(board *blcu.BLCU, target string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/blcu/download?board="+target, nil)
+	recorder := httptest.NewRecorder()
+	board.Handlers("/blcu")["/blcu/download"].ServeHTTP(recorder, request)
+	return recorder
+}
+
+// cancelRecorder closes the request as soon as the download starts streaming
+type cancelRecorder struct {
+	*httptest.ResponseRecorder
+	cancel context.CancelFunc
+}
+
+func (recorder *cancelRecorder) Write(p []byte) (int, error) {
+	recorder.cancel()
+	return recorder.ResponseRecorder.Write(p)
+}
+
+func TestTransfers(t *testing.T) {
+	image := bytes.Repeat([]byte{0xCA, 0xFE, 0x01}, 4000)
+
+	t.Run("uploaded image is read back", func(t *testing.T) {
+		board, server := newTestBLCU(t, func(config *blcu.BLCUConfig) { config.Verify = true })
+
+		status, report := upload(t, board, "VCU", image)
+		if status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d (%s)", http.StatusOK, status, report.Error)
+		}
+		if report.Verified == nil || !*report.Verified {
+			t.Fatalf("expected upload to be verified")
+		}
+		if !bytes.HasPrefix(server.Memory("VCU"), image) {
+			t.Fatalf("board memory does not start with the uploaded image")
+		}
+
+		response := download(board, "VCU")
+		if response.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+		}
+		if response.Body.Len() != blcu.FlashMemorySize || !bytes.HasPrefix(response.Body.Bytes(), image) {
+			t.Fatalf("downloaded flash does not match the uploaded image")
+		}
+	})
+
+	t.Run("failed transfer is retried", func(t *testing.T) {
+		board, server := newTestBLCU(t, func(config *blcu.BLCUConfig) { config.Retries = 1 })
+		server.FailTransfers(1)
+
+		status, report := upload(t, board, "BMSL", image)
+		if status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d (%s)", http.StatusOK, status, report.Error)
+		}
+		if report.Attempts != 2 {
+			t.Fatalf("expected 2 attempts, got %d", report.Attempts)
+		}
+	})
+
+	t.Run("attempts are counted when retries run out", func(t *testing.T) {
+		board, server := newTestBLCU(t, func(config *blcu.BLCUConfig) { config.Retries = 1 })
+		server.FailTransfers(2)
+
+		status, report := upload(t, board, "BMSL", image)
+		if status != http.StatusBadGateway {
+			t.Fatalf("expected status %d, got %d", http.StatusBadGateway, status)
+		}
+		if report.Attempts != 2 {
+			t.Fatalf("expected 2 attempts, got %d", report.Attempts)
+		}
+	})
+
+	t.Run("upload fails without ack", func(t *testing.T) {
+		board, server := newTestBLCU(t, func(config *blcu.BLCUConfig) {})
+		server.DropAcks(true)
+
+		status, report := upload(t, board, "VCU", image)
+		if status != http.StatusBadGateway || report.Error == "" {
+			t.Fatalf("expected upload to fail, got status %d", status)
+		}
+	})
+
+	t.Run("upload fails for unknown board", func(t *testing.T) {
+		board, _ := newTestBLCU(t, func(config *blcu.BLCUConfig) {})
+
+		status, _ := upload(t, board, "LCU", image)
+		if status != http.StatusBadGateway {
+			t.Fatalf("expected status %d, got %d", http.StatusBadGateway, status)
+		}
+	})
+
+	t.Run("download fails when the transfer fails", func(t *testing.T) {
+		board, server := newTestBLCU(t, func(config *blcu.BLCUConfig) {})
+		server.FailTransfers(1)
+
+		response := download(board, "VCU")
+		if response.Code != http.StatusBadGateway {
+			t.Fatalf("expected status %d, got %d", http.StatusBadGateway, response.Code)
+		}
+	})
+	t.Run("closing the request interrupts the download", func(t *testing.T) {
+		board, _ := newTestBLCU(t, func(config *blcu.BLCUConfig) {})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		request := httptest.NewRequest(http.MethodGet, "/blcu/download?board=VCU", nil).WithContext(ctx)
+		recorder := &cancelRecorder{ResponseRecorder: httptest.NewRecorder(), cancel: cancel}
+		board.Handlers("/blcu")["/blcu/download"].ServeHTTP(recorder, request)
+
+		if recorder.Body.Len() == 0 || recorder.Body.Len() >= blcu.FlashMemorySize {
+			t.Fatalf("expected the download to stop after it started, got %d bytes", recorder.Body.Len())
+		}
+	})
+}
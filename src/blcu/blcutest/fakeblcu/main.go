@@ -0,0 +1,69 @@
+// Command fakeblcu runs the blcutest stand-in as a process, so the backend (or a pod simulator)
+// can flash and read boards without the BLCU. Point the BLCU address of the ADE to the host running it.
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/blcu"
+	"github.com/HyperloopUPV-H8/Backend-H8/blcu/blcutest"
+	trace "github.com/rs/zerolog/log"
+)
+
+func main() {
+	tftpAddr := flag.String("tftp", "127.0.0.1:69", "address of the TFTP server, the BLCU address and TFTP port of the ADE")
+	pipeAddr := flag.String("pipe", "127.0.0.1:50500", "address the backend pipe dials, the BLCU address and TCP server port of the ADE")
+	boards := flag.String("boards", "", "board ids as NAME=ID pairs separated by commas")
+	uploadId := flag.Uint("upload", 700, "id of the upload order")
+	downloadId := flag.Uint("download", 701, "id of the download order")
+	ackId := flag.Uint("ack", 0, "id of the blcu_ack message")
+	flag.Parse()
+
+	boardIds, err := parseBoards(*boards)
+	if err != nil {
+		trace.Fatal().Err(err).Msg("parsing boards")
+	}
+
+	var config blcu.BLCUConfig
+	config.Packets.Upload = blcu.PacketData{Id: uint16(*uploadId)}
+	config.Packets.Download = blcu.PacketData{Id: uint16(*downloadId)}
+
+	server, err := blcutest.ListenServer(*tftpAddr, boardIds, config)
+	if err != nil {
+		trace.Fatal().Err(err).Msg("starting TFTP server")
+	}
+	defer server.Close()
+
+	pipe, err := server.ListenPipe(*pipeAddr, uint16(*ackId))
+	if err != nil {
+		trace.Fatal().Err(err).Msg("listening pipe")
+	}
+	defer pipe.Close()
+
+	trace.Info().Str("tftp", *tftpAddr).Str("pipe", *pipeAddr).Any("boards", boardIds).Msg("fake BLCU running")
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+}
+
+func parseBoards(value string) (map[string]uint16, error) {
+	boardIds := make(map[string]uint16)
+	for _, pair := range strings.Split(value, ",") {
+		if pair == "" {
+			continue
+		}
+
+		name, id, _ := strings.Cut(pair, "=")
+		parsed, err := strconv.ParseUint(id, 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		boardIds[strings.TrimSpace(name)] = uint16(parsed)
+	}
+	return boardIds, nil
+}
@@ -0,0 +1,115 @@
+package blcutest
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/rs/zerolog"
+)
+
+// keepaliveId is the id of the keepalive frames the backend pipes send, they carry no payload
+const keepaliveId = 0x45
+
+// Pipe is the BLCU end of the backend pipe. The backend dials it like the real board and the upload and download
+// orders it receives are answered with the blcu_ack message, so the ack goes through the pipe readers and
+// vehicle.Listen. The board field of the orders must be a uint16, as the blcu package sends it
+type Pipe struct {
+	listener *net.TCPListener
+	server   *Server
+	ackId    uint16
+	trace    zerolog.Logger
+}
+
+// ListenPipe accepts the backend pipes on addr, which is the BLCU address and TCP server port for the backend
+func (server *Server) ListenPipe(addr string, ackId uint16) (*Pipe, error) {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.ListenTCP("tcp", tcpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := &Pipe{
+		listener: listener,
+		server:   server,
+		ackId:    ackId,
+		trace:    server.trace.With().Str("pipe", listener.Addr().String()).Logger(),
+	}
+
+	go pipe.serve()
+
+	return pipe, nil
+}
+
+func (pipe *Pipe) Addr() net.TCPAddr {
+	return *pipe.listener.Addr().(*net.TCPAddr)
+}
+
+func (pipe *Pipe) Close() error {
+	return pipe.listener.Close()
+}
+
+func (pipe *Pipe) serve() {
+	for {
+		conn, err := pipe.listener.AcceptTCP()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			pipe.trace.Error().Err(err).Msg("accepting backend")
+			continue
+		}
+
+		pipe.trace.Info().Str("backend", conn.RemoteAddr().String()).Msg("backend connected")
+		go pipe.handle(conn)
+	}
+}
+
+func (pipe *Pipe) handle(conn *net.TCPConn) {
+	defer conn.Close()
+
+	for {
+		var orderId uint16
+		if err := binary.Read(conn, binary.LittleEndian, &orderId); err != nil {
+			pipe.logClosed(err)
+			return
+		}
+
+		if orderId == keepaliveId {
+			continue
+		}
+
+		var boardId uint16
+		if err := binary.Read(conn, binary.LittleEndian, &boardId); err != nil {
+			pipe.logClosed(err)
+			return
+		}
+
+		ack, err := pipe.server.order(orderId, boardId)
+		if err != nil {
+			pipe.trace.Error().Err(err).Msg("order")
+			continue
+		}
+
+		if !ack {
+			continue
+		}
+
+		if err := binary.Write(conn, binary.LittleEndian, pipe.ackId); err != nil {
+			pipe.logClosed(err)
+			return
+		}
+	}
+}
+
+func (pipe *Pipe) logClosed(err error) {
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		pipe.trace.Info().Msg("backend disconnected")
+		return
+	}
+	pipe.trace.Error().Err(err).Msg("backend connection")
+}
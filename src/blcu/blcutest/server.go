@@ -0,0 +1,257 @@
+// Package blcutest provides a local stand-in for the BLCU so transfers can be exercised without the board.
+package blcutest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/blcu"
+	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+	"github.com/pin/tftp/v3"
+	"github.com/rs/zerolog"
+	trace "github.com/rs/zerolog/log"
+)
+
+const erasedByte = 0xFF
+
+var ErrTransferFailed = errors.New("transfer failed")
+
+// Server answers upload and download orders like the BLCU does and serves the flash of the requested board over TFTP.
+// Orders reach it either through SendOrder, which acks them with the callback set with SetOnAck, or through a Pipe,
+// which answers them with the blcu_ack message like the real board.
+type Server struct {
+	tftp *tftp.Server
+	conn *servedConn
+
+	config   blcu.BLCUConfig
+	idToName map[uint16]string
+
+	mx            *sync.Mutex
+	memory        map[string][]byte
+	target        string
+	onAck         func()
+	dropAcks      bool
+	failTransfers int
+
+	trace zerolog.Logger
+}
+
+// NewServer starts a TFTP server listening on a random local port
+func NewServer(boardIds map[string]uint16, config blcu.BLCUConfig) (*Server, error) {
+	return ListenServer("127.0.0.1:0", boardIds, config)
+}
+
+// ListenServer starts a TFTP server listening on addr, which is the BLCU address and TFTP port for the backend
+func ListenServer(addr string, boardIds map[string]uint16, config blcu.BLCUConfig) (*Server, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	idToName := make(map[uint16]string, len(boardIds))
+	for name, id := range boardIds {
+		idToName[id] = name
+	}
+
+	server := &Server{
+		conn:     &servedConn{UDPConn: conn, ready: make(chan struct{})},
+		config:   config,
+		idToName: idToName,
+		mx:       &sync.Mutex{},
+		memory:   make(map[string][]byte),
+		onAck:    func() {},
+		trace:    trace.With().Str("component", "blcuTest").Logger(),
+	}
+
+	server.tftp = tftp.NewServer(server.handleRead, server.handleWrite)
+	go server.tftp.Serve(server.conn)
+
+	return server, nil
+}
+
+// Addr is the address to give to blcu.NewBLCU
+func (server *Server) Addr() net.TCPAddr {
+	addr := server.conn.UDPConn.LocalAddr().(*net.UDPAddr)
+	return net.TCPAddr{IP: addr.IP, Port: addr.Port}
+}
+
+func (server *Server) Close() {
+	// shutting down before the server is serving would dereference its nil connection
+	<-server.conn.ready
+	server.tftp.Shutdown()
+}
+
+func (server *Server) SetOnAck(onAck func()) {
+	server.mx.Lock()
+	defer server.mx.Unlock()
+	server.onAck = onAck
+}
+
+// DropAcks makes the server ignore orders, as a disconnected BLCU would
+func (server *Server) DropAcks(drop bool) {
+	server.mx.Lock()
+	defer server.mx.Unlock()
+	server.dropAcks = drop
+}
+
+// FailTransfers makes the next count TFTP transfers fail
+func (server *Server) FailTransfers(count int) {
+	server.mx.Lock()
+	defer server.mx.Unlock()
+	server.failTransfers = count
+}
+
+// Memory returns the flash contents of the board
+func (server *Server) Memory(board string) []byte {
+	server.mx.Lock()
+	defer server.mx.Unlock()
+	return append([]byte{}, server.flash(board)...)
+}
+
+func (server *Server) SetMemory(board string, data []byte) {
+	server.mx.Lock()
+	defer server.mx.Unlock()
+	copy(server.flash(board), data)
+}
+
+// SendOrder is meant to replace vehicle.SendOrder with blcu.SetSendOrder
+func (server *Server) SendOrder(order models.Order) error {
+	var field string
+	switch order.ID {
+	case server.config.Packets.Upload.Id:
+		field = server.config.Packets.Upload.Field
+	case server.config.Packets.Download.Id:
+		field = server.config.Packets.Download.Field
+	default:
+		return fmt.Errorf("unexpected order %d", order.ID)
+	}
+
+	value, ok := order.Fields[field]
+	if !ok {
+		return fmt.Errorf("missing field %s", field)
+	}
+
+	id, ok := value.Value.(uint16)
+	if !ok {
+		return fmt.Errorf("invalid board id %v", value.Value)
+	}
+
+	ack, err := server.order(order.ID, id)
+	if err != nil {
+		return err
+	}
+
+	if ack {
+		server.mx.Lock()
+		onAck := server.onAck
+		server.mx.Unlock()
+		go onAck()
+	}
+
+	return nil
+}
+
+// order targets the board for the next transfer and reports whether the order has to be acked
+func (server *Server) order(orderId uint16, boardId uint16) (bool, error) {
+	if orderId != server.config.Packets.Upload.Id && orderId != server.config.Packets.Download.Id {
+		return false, fmt.Errorf("unexpected order %d", orderId)
+	}
+
+	board, ok := server.idToName[boardId]
+	if !ok {
+		return false, fmt.Errorf("unknown board id %d", boardId)
+	}
+
+	server.mx.Lock()
+	server.target = board
+	dropAck := server.dropAcks
+	server.mx.Unlock()
+
+	server.trace.Debug().Uint16("order", orderId).Str("board", board).Bool("drop", dropAck).Msg("order")
+	return !dropAck, nil
+}
+
+// handleRead serves a download, the whole flash is sent like the real board does
+func (server *Server) handleRead(filename string, rf io.ReaderFrom) error {
+	server.mx.Lock()
+	if err := server.takeFailure(); err != nil {
+		server.mx.Unlock()
+		return err
+	}
+	data := append([]byte{}, server.flash(server.target)...)
+	server.mx.Unlock()
+
+	_, err := rf.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+func (server *Server) handleWrite(filename string, wt io.WriterTo) error {
+	server.mx.Lock()
+	if err := server.takeFailure(); err != nil {
+		server.mx.Unlock()
+		return err
+	}
+	board := server.target
+	server.mx.Unlock()
+
+	buffer := &bytes.Buffer{}
+	if _, err := wt.WriteTo(buffer); err != nil {
+		return err
+	}
+
+	if buffer.Len() > blcu.FlashMemorySize {
+		return fmt.Errorf("image of %d bytes does not fit in flash", buffer.Len())
+	}
+
+	server.mx.Lock()
+	defer server.mx.Unlock()
+	flash := server.flash(board)
+	for i := range flash {
+		flash[i] = erasedByte
+	}
+	copy(flash, buffer.Bytes())
+
+	return nil
+}
+
+// takeFailure must be called with mx held
+func (server *Server) takeFailure() error {
+	if server.failTransfers <= 0 {
+		return nil
+	}
+
+	server.failTransfers--
+	return ErrTransferFailed
+}
+
+// flash must be called with mx held
+func (server *Server) flash(board string) []byte {
+	flash, ok := server.memory[board]
+	if !ok {
+		flash = bytes.Repeat([]byte{erasedByte}, blcu.FlashMemorySize)
+		server.memory[board] = flash
+	}
+
+	return flash
+}
+
+// servedConn signals when the TFTP server starts reading requests
+type servedConn struct {
+	*net.UDPConn
+	ready chan struct{}
+	once  sync.Once
+}
+
+func (conn *servedConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	conn.once.Do(func() { close(conn.ready) })
+	return conn.UDPConn.ReadFrom(p)
+}
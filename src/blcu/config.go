@@ -1,5 +1,7 @@
 package blcu
 
+import "github.com/HyperloopUPV-H8/Backend-H8/blcu/firmware"
+
 type BLCUConfig struct {
 	Packets struct {
 		Upload   PacketData
@@ -9,9 +11,24 @@ type BLCUConfig struct {
 
 	DownloadPath string `toml:"download_path,omitempty"`
 
+	AckTimeout  string `toml:"ack_timeout,omitempty"`
+	TFTPTimeout string `toml:"tftp_timeout,omitempty"`
+	TFTPRetries int    `toml:"tftp_retries,omitempty"`
+	// Retries is the number of times a failed upload is attempted again from the start
+	Retries int `toml:"retries,omitempty"`
+	// Verify reads the board back after uploading and compares its checksum with the uploaded file
+	Verify bool `toml:"verify,omitempty"`
+
+	Firmware firmware.Config `toml:"firmware"`
+	// Endpoint serves uploads and downloads over HTTP so images don't travel inside websocket messages
+	Endpoint string `toml:"endpoint,omitempty"`
+
 	Topics struct {
 		Upload   string
 		Download string
+		Cancel   string
+		Queue    string
+		Progress string
 	}
 }
 
@@ -27,4 +44,10 @@ const (
 	BLCU_HANDLER_NAME   = "blcu"
 	BLCU_INPUT_CHAN_BUF = 100
 	BLCU_ACK_CHAN_BUF   = 1
+
+	// MAX_KEPT_DOWNLOADS is the number of images read by websocket downloads kept to be fetched over HTTP
+	MAX_KEPT_DOWNLOADS = 4
+
+	DEFAULT_ACK_TIMEOUT  = "10s"
+	DEFAULT_TFTP_TIMEOUT = "5s"
 )
@@ -1,46 +1,36 @@
 package blcu
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"sync"
 	"time"
 
-	"github.com/HyperloopUPV-H8/Backend-H8/common"
 	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
 	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
-	"github.com/pin/tftp/v3"
 )
 
 type downloadRequest struct {
 	Board string `json:"board"`
 }
 
-func (blcu *BLCU) download(client wsModels.Client, payload json.RawMessage) (string, []byte, error) {
+func (blcu *BLCU) download(ctx context.Context, board string, output io.Writer, onProgress func(float64)) error {
 	blcu.trace.Debug().Msg("Handling download")
-	var request downloadRequest
-	if err := json.Unmarshal(payload, &request); err != nil {
-		blcu.trace.Error().Err(err).Stack().Msg("Unmarshal payload")
-		return "", nil, err
-	}
 
-	blcu.notifyDownloadProgress(client, 0)
+	onProgress(0)
 
-	if err := blcu.requestDownload(request.Board); err != nil {
+	if err := blcu.requestDownload(ctx, board); err != nil {
 		blcu.trace.Error().Err(err).Stack().Msg("Request download")
-		return request.Board, nil, err
+		return err
 	}
 
-	buffer := &bytes.Buffer{}
-	err := blcu.ReadTFTP(buffer, func(percentage float64) { blcu.notifyDownloadProgress(client, percentage) })
-
-	return request.Board, buffer.Bytes(), err
+	return blcu.ReadTFTP(ctx, output, onProgress)
 }
 
-func (blcu *BLCU) requestDownload(board string) error {
+func (blcu *BLCU) requestDownload(ctx context.Context, board string) error {
 	blcu.trace.Info().Str("board", board).Msg("Requesting download")
 
 	downloadOrder, err := blcu.createDownloadOrder(board)
@@ -49,16 +39,7 @@ func (blcu *BLCU) requestDownload(board string) error {
 		return err
 	}
 
-	if err := blcu.sendOrder(downloadOrder); err != nil {
-		return err
-	}
-
-	// TODO: remove hardcoded timeout
-	if _, err := common.ReadTimeout(blcu.ackChannel, time.Second*10); err != nil {
-		return err
-	}
-
-	return nil
+	return blcu.waitAck(ctx, downloadOrder)
 }
 
 func (blcu *BLCU) createDownloadOrder(board string) (models.Order, error) {
@@ -82,10 +63,11 @@ func (blcu *BLCU) createDownloadOrder(board string) (models.Order, error) {
 
 const FlashMemorySize = 786432
 
-func (blcu *BLCU) ReadTFTP(output io.Writer, onProgress func(float64)) error {
+// ReadTFTP stops the transfer with the context error once ctx is cancelled
+func (blcu *BLCU) ReadTFTP(ctx context.Context, output io.Writer, onProgress func(float64)) error {
 	blcu.trace.Info().Msg("Reading TFTP")
 
-	client, err := tftp.NewClient(blcu.addr.String())
+	client, err := blcu.newTFTPClient()
 	if err != nil {
 		return err
 	}
@@ -95,46 +77,33 @@ func (blcu *BLCU) ReadTFTP(output io.Writer, onProgress func(float64)) error {
 		return err
 	}
 
-	download := NewDownload(output, FlashMemorySize, onProgress)
+	download := NewDownload(ctx, output, FlashMemorySize, onProgress)
 	_, err = receiver.WriteTo(&download)
 
 	return err
 }
 
+// downloadResponse only carries the progress, once done the image is fetched from the HTTP download endpoint with the job id
 type downloadResponse struct {
 	Percentage float64 `json:"percentage"`
 	IsFailure  bool    `json:"failure"`
-	File       []byte  `json:"file,omitempty"`
+	Job        string  `json:"job,omitempty"`
 }
 
 func (blcu *BLCU) notifyDownloadFailure(client wsModels.Client) {
 	blcu.trace.Warn().Msg("Download failed")
 
-	msgBuf, err := wsModels.NewMessageBuf(blcu.config.Topics.Download, downloadResponse{IsFailure: true, File: nil, Percentage: 0.0})
-
-	//TODO: handle errors
-	if err != nil {
-		return
-	}
-
-	err = client.Write(msgBuf)
+	err := client.WriteMessage(blcu.config.Topics.Download, downloadResponse{IsFailure: true, Percentage: 0.0})
 
 	if err != nil {
 		return
 	}
 }
 
-func (blcu *BLCU) notifyDownloadSuccess(client wsModels.Client, data []byte) {
-	blcu.trace.Info().Msg("Download success")
+func (blcu *BLCU) notifyDownloadSuccess(client wsModels.Client, job string) {
+	blcu.trace.Info().Str("job", job).Msg("Download success")
 
-	msgBuf, err := wsModels.NewMessageBuf(blcu.config.Topics.Download, downloadResponse{IsFailure: false, File: data, Percentage: 100})
-
-	//TODO: handle errors
-	if err != nil {
-		return
-	}
-
-	err = client.Write(msgBuf)
+	err := client.WriteMessage(blcu.config.Topics.Download, downloadResponse{IsFailure: false, Job: job, Percentage: 100})
 
 	if err != nil {
 		return
@@ -142,14 +111,7 @@ func (blcu *BLCU) notifyDownloadSuccess(client wsModels.Client, data []byte) {
 }
 
 func (blcu *BLCU) notifyDownloadProgress(client wsModels.Client, percentage float64) {
-	msgBuf, err := wsModels.NewMessageBuf(blcu.config.Topics.Download, downloadResponse{IsFailure: false, File: nil, Percentage: percentage})
-
-	//TODO: handle errors
-	if err != nil {
-		return
-	}
-
-	err = client.Write(msgBuf)
+	err := client.WriteMessage(blcu.config.Topics.Download, downloadResponse{IsFailure: false, Percentage: percentage})
 
 	if err != nil {
 		return
@@ -159,6 +121,11 @@ func (blcu *BLCU) notifyDownloadProgress(client wsModels.Client, percentage floa
 func (blcu *BLCU) writeDownloadFile(board string, data []byte) error {
 	blcu.trace.Info().Msg("Creating download file")
 
+	if blcu.firmware != nil {
+		_, err := blcu.firmware.Add(board, fmt.Sprintf("download-%d", time.Now().Unix()), "read back from the board", data)
+		return err
+	}
+
 	err := os.MkdirAll(blcu.config.DownloadPath, 0777)
 	if err != nil {
 		return err
@@ -171,15 +138,58 @@ func (blcu *BLCU) writeDownloadFile(board string, data []byte) error {
 	return os.WriteFile(path.Join(blcu.config.DownloadPath, fmt.Sprintf("%s-%d.bin", board, time.Now().Unix())), data, 0777)
 }
 
+type downloadedImage struct {
+	board string
+	data  []byte
+}
+
+// downloadCache keeps the last images read by websocket downloads until they are fetched over HTTP
+type downloadCache struct {
+	mx     *sync.Mutex
+	jobs   []string
+	images map[string]downloadedImage
+}
+
+func newDownloadCache() *downloadCache {
+	return &downloadCache{
+		mx:     &sync.Mutex{},
+		jobs:   make([]string, 0, MAX_KEPT_DOWNLOADS),
+		images: make(map[string]downloadedImage, MAX_KEPT_DOWNLOADS),
+	}
+}
+
+func (cache *downloadCache) add(job string, image downloadedImage) {
+	cache.mx.Lock()
+	defer cache.mx.Unlock()
+
+	if len(cache.jobs) == MAX_KEPT_DOWNLOADS {
+		delete(cache.images, cache.jobs[0])
+		cache.jobs = cache.jobs[1:]
+	}
+
+	cache.jobs = append(cache.jobs, job)
+	cache.images[job] = image
+}
+
+func (cache *downloadCache) get(job string) (downloadedImage, bool) {
+	cache.mx.Lock()
+	defer cache.mx.Unlock()
+
+	image, ok := cache.images[job]
+	return image, ok
+}
+
 type Download struct {
+	ctx        context.Context
 	writer     io.Writer
 	onProgress func(float64)
 	total      int
 	current    int
 }
 
-func NewDownload(writer io.Writer, size int, onProgress func(float64)) Download {
+func NewDownload(ctx context.Context, writer io.Writer, size int, onProgress func(float64)) Download {
 	return Download{
+		ctx:        ctx,
 		writer:     writer,
 		onProgress: onProgress,
 		total:      size,
@@ -188,6 +198,10 @@ func NewDownload(writer io.Writer, size int, onProgress func(float64)) Download
 }
 
 func (download *Download) Write(p []byte) (n int, err error) {
+	if err := download.ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	n, err = download.writer.Write(p)
 	if err == nil {
 		download.current += n
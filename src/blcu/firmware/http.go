@@ -0,0 +1,107 @@
+package firmware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/gorilla/mux"
+)
+
+// Handlers returns the firmware endpoints keyed by their path relative to endpoint
+func (store *Store) Handlers(endpoint string) map[string]http.Handler {
+	return map[string]http.Handler{
+		endpoint:                    http.HandlerFunc(store.handleArtifacts),
+		endpoint + "/{id}":          http.HandlerFunc(store.handleArtifact),
+		endpoint + "/{id}/download": http.HandlerFunc(store.handleDownload),
+	}
+}
+
+func (store *Store) handleArtifacts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		common.WriteJSON(w, http.StatusOK, store.List(r.URL.Query().Get("board")))
+	case http.MethodPost:
+		store.handleUpload(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (store *Store) handleUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, store.maxSize+(1<<20))
+	if err := r.ParseMultipartForm(store.maxSize); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	board := r.FormValue("board")
+	if err := store.ValidateBoard(board); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	artifact, err := store.Add(board, r.FormValue("version"), r.FormValue("notes"), data)
+	if err != nil {
+		store.trace.Error().Err(err).Str("board", board).Msg("adding artifact")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	common.WriteJSON(w, http.StatusCreated, artifact)
+}
+
+func (store *Store) handleArtifact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	artifact, err := store.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	common.WriteJSON(w, http.StatusOK, artifact)
+}
+
+func (store *Store) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	artifact, data, err := store.Read(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%s.bin", artifact.Board, artifact.Version)))
+	w.Write(data)
+}
+
+var errorStatuses = []common.ErrorStatus{
+	{Err: ErrArtifactNotFound, Status: http.StatusNotFound},
+	{Err: ErrInvalidBoard, Status: http.StatusBadRequest},
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	common.WriteError(w, err, errorStatuses)
+}
@@ -0,0 +1,26 @@
+package firmware
+
+import "time"
+
+type Artifact struct {
+	Id        string        `json:"id"`
+	Board     string        `json:"board"`
+	Version   string        `json:"version"`
+	Notes     string        `json:"notes"`
+	Checksum  string        `json:"checksum"`
+	Size      int64         `json:"size"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Flashes   []FlashRecord `json:"flashes"`
+}
+
+type FlashRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Success   bool      `json:"success"`
+	Verified  *bool     `json:"verified"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type Config struct {
+	Path     string `toml:"path"`
+	Endpoint string `toml:"endpoint"`
+}
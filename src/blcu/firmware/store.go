@@ -0,0 +1,230 @@
+package firmware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	trace "github.com/rs/zerolog/log"
+)
+
+const indexFileName = "index.json"
+
+var (
+	ErrArtifactNotFound = errors.New("artifact not found")
+	ErrInvalidBoard     = errors.New("invalid board")
+)
+
+// Store keeps firmware binaries on disk, one folder per board, with their metadata in an index file
+type Store struct {
+	path    string
+	maxSize int64
+	// boards are the only board names accepted, they are used as folder names
+	boards    common.Set[string]
+	indexMx   *sync.Mutex
+	artifacts map[string]Artifact
+	trace     zerolog.Logger
+}
+
+func NewStore(path string, maxSize int64) (*Store, error) {
+	trace.Info().Str("path", path).Msg("new firmware store")
+
+	if err := os.MkdirAll(path, 0777); err != nil {
+		return nil, err
+	}
+
+	store := &Store{
+		path:      path,
+		maxSize:   maxSize,
+		boards:    common.NewSet[string](),
+		indexMx:   &sync.Mutex{},
+		artifacts: make(map[string]Artifact),
+		trace:     trace.With().Str("component", "firmwareStore").Logger(),
+	}
+
+	raw, err := os.ReadFile(store.indexPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(raw, &store.artifacts); err != nil {
+		return nil, fmt.Errorf("reading firmware index: %w", err)
+	}
+
+	return store, nil
+}
+
+func (store *Store) MaxSize() int64 {
+	return store.maxSize
+}
+
+// SetBoards sets the boards of the ADE, artifacts for any other board are rejected
+func (store *Store) SetBoards(boards []string) {
+	store.boards = common.NewSet[string]()
+	for _, board := range boards {
+		store.boards.Add(board)
+	}
+}
+
+// ValidateBoard checks board is a known board before it is used as a path
+func (store *Store) ValidateBoard(board string) error {
+	if board == "" || board == "." || strings.Contains(board, "..") || strings.ContainsAny(board, `/\`) {
+		return fmt.Errorf("%w %q", ErrInvalidBoard, board)
+	}
+
+	if !store.boards.Has(board) {
+		return fmt.Errorf("%w %q: unknown board", ErrInvalidBoard, board)
+	}
+
+	return nil
+}
+
+func (store *Store) Add(board string, version string, notes string, data []byte) (Artifact, error) {
+	if err := store.ValidateBoard(board); err != nil {
+		return Artifact{}, err
+	}
+
+	if int64(len(data)) > store.maxSize {
+		return Artifact{}, fmt.Errorf("artifact size %d exceeds %d bytes", len(data), store.maxSize)
+	}
+
+	artifact := Artifact{
+		Id:        uuid.NewString(),
+		Board:     board,
+		Version:   version,
+		Notes:     notes,
+		Checksum:  Checksum(data),
+		Size:      int64(len(data)),
+		CreatedAt: time.Now(),
+		Flashes:   make([]FlashRecord, 0),
+	}
+
+	if err := os.MkdirAll(filepath.Join(store.path, board), 0777); err != nil {
+		return Artifact{}, err
+	}
+
+	if err := os.WriteFile(store.binaryPath(artifact), data, 0666); err != nil {
+		return Artifact{}, err
+	}
+
+	store.indexMx.Lock()
+	defer store.indexMx.Unlock()
+
+	store.artifacts[artifact.Id] = artifact
+	if err := store.saveIndex(); err != nil {
+		return Artifact{}, err
+	}
+
+	store.trace.Info().Str("id", artifact.Id).Str("board", board).Str("version", version).Msg("artifact added")
+	return artifact, nil
+}
+
+// List returns the artifacts of board (or all of them if board is empty), newest first
+func (store *Store) List(board string) []Artifact {
+	store.indexMx.Lock()
+	defer store.indexMx.Unlock()
+
+	artifacts := make([]Artifact, 0, len(store.artifacts))
+	for _, artifact := range store.artifacts {
+		if board == "" || artifact.Board == board {
+			artifacts = append(artifacts, artifact)
+		}
+	}
+
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].CreatedAt.After(artifacts[j].CreatedAt)
+	})
+
+	return artifacts
+}
+
+func (store *Store) Get(id string) (Artifact, error) {
+	store.indexMx.Lock()
+	defer store.indexMx.Unlock()
+
+	artifact, ok := store.artifacts[id]
+	if !ok {
+		return Artifact{}, ErrArtifactNotFound
+	}
+
+	return artifact, nil
+}
+
+// Read returns the binary of the artifact after checking it wasn't modified on disk
+func (store *Store) Read(id string) (Artifact, []byte, error) {
+	artifact, err := store.Get(id)
+	if err != nil {
+		return Artifact{}, nil, err
+	}
+
+	if err := store.ValidateBoard(artifact.Board); err != nil {
+		return Artifact{}, nil, err
+	}
+
+	data, err := os.ReadFile(store.binaryPath(artifact))
+	if err != nil {
+		return Artifact{}, nil, err
+	}
+
+	if Checksum(data) != artifact.Checksum {
+		return Artifact{}, nil, fmt.Errorf("checksum mismatch for artifact %s", id)
+	}
+
+	return artifact, data, nil
+}
+
+func (store *Store) RecordFlash(id string, record FlashRecord) error {
+	store.indexMx.Lock()
+	defer store.indexMx.Unlock()
+
+	artifact, ok := store.artifacts[id]
+	if !ok {
+		return ErrArtifactNotFound
+	}
+
+	artifact.Flashes = append(artifact.Flashes, record)
+	store.artifacts[id] = artifact
+
+	return store.saveIndex()
+}
+
+// saveIndex must be called with indexMx held, it writes to a temporary file first so a crash never leaves a partial index
+func (store *Store) saveIndex() error {
+	raw, err := json.MarshalIndent(store.artifacts, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := store.indexPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0666); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, store.indexPath())
+}
+
+func (store *Store) indexPath() string {
+	return filepath.Join(store.path, indexFileName)
+}
+
+func (store *Store) binaryPath(artifact Artifact) string {
+	return filepath.Join(store.path, artifact.Board, fmt.Sprintf("%s.bin", artifact.Id))
+}
+
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
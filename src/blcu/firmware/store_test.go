@@ -0,0 +1,45 @@
+package firmware
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreBoards(t *testing.T) {
+	tests := []struct {
+		name  string
+		board string
+		want  error
+	}{
+		{"known board", "VCU", nil},
+		{"unknown board", "XYZ", ErrInvalidBoard},
+		{"empty board", "", ErrInvalidBoard},
+		{"parent directory", "../../x", ErrInvalidBoard},
+		{"nested path", "VCU/x", ErrInvalidBoard},
+		{"windows separator", `VCU\x`, ErrInvalidBoard},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			store, err := NewStore(filepath.Join(root, "firmware"), 1024)
+			if err != nil {
+				t.Fatalf("creating store: %s", err)
+			}
+			store.SetBoards([]string{"VCU", "BMSL"})
+
+			_, err = store.Add(test.board, "1", "", []byte{1, 2, 3})
+
+			if !errors.Is(err, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, err)
+			}
+
+			entries, _ := os.ReadDir(root)
+			if len(entries) != 1 {
+				t.Fatalf("expected only the store folder, got %d entries", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package blcu
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+)
+
+// httpJobOwner owns jobs requested over HTTP, they are cancelled when the request is closed
+const httpJobOwner = "http"
+
+// TransferProgress is broadcast on the progress topic for transfers requested over HTTP
+type TransferProgress struct {
+	Job        string  `json:"job"`
+	Kind       string  `json:"kind"`
+	Board      string  `json:"board"`
+	Position   int     `json:"position"`
+	State      string  `json:"state"`
+	Percentage float64 `json:"percentage"`
+}
+
+// Handlers returns the transfer endpoints keyed by their path relative to endpoint.
+// download?board=X reads the board, download?job=ID returns the image read by a websocket download job.
+// They command the vehicle, so they must only be served to operators
+func (blcu *BLCU) Handlers(endpoint string) map[string]http.Handler {
+	return map[string]http.Handler{
+		endpoint + "/upload":   http.HandlerFunc(blcu.handleUpload),
+		endpoint + "/download": http.HandlerFunc(blcu.handleDownload),
+	}
+}
+
+func (blcu *BLCU) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, FlashMemorySize+(1<<20))
+	if err := r.ParseMultipartForm(FlashMemorySize); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request := uploadRequest{Board: r.FormValue("board"), Artifact: r.FormValue("artifact")}
+	if request.Board == "" {
+		http.Error(w, "missing board", http.StatusBadRequest)
+		return
+	}
+
+	if request.Artifact == "" {
+		data, err := readFormFile(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		request.data = data
+	}
+
+	var report UploadReport
+	blcu.runHTTPJob(r, UploadJobKind, request.Board, func(job *job, onProgress func(float64)) {
+		if job.isCancelled() {
+			return
+		}
+
+		var err error
+		report, err = blcu.upload(job.ctx, request, onProgress)
+		if err != nil {
+			report.Error = err.Error()
+		}
+		blcu.recordFlash(report)
+	})
+
+	if report.Error != "" {
+		common.WriteJSON(w, http.StatusBadGateway, report)
+		return
+	}
+
+	common.WriteJSON(w, http.StatusOK, report)
+}
+
+func (blcu *BLCU) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if job := r.URL.Query().Get("job"); job != "" {
+		blcu.serveDownloaded(w, job)
+		return
+	}
+
+	board := r.URL.Query().Get("board")
+	if board == "" {
+		http.Error(w, "missing board", http.StatusBadRequest)
+		return
+	}
+
+	output := &streamWriter{
+		writer:   w,
+		filename: fmt.Sprintf("%s-%d.bin", board, time.Now().Unix()),
+	}
+
+	var err error
+	buffer := &bytes.Buffer{}
+	blcu.runHTTPJob(r, DownloadJobKind, board, func(job *job, onProgress func(float64)) {
+		if job.isCancelled() {
+			return
+		}
+
+		err = blcu.download(job.ctx, board, io.MultiWriter(output, buffer), onProgress)
+	})
+
+	if err != nil {
+		blcu.trace.Error().Err(err).Str("board", board).Msg("streaming download")
+		// once the image started streaming the status can no longer be changed
+		if !output.started {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	if err := blcu.writeDownloadFile(board, buffer.Bytes()); err != nil {
+		blcu.trace.Error().Err(err).Str("board", board).Msg("writing download file")
+	}
+}
+
+// serveDownloaded sends the image read by a websocket download job
+func (blcu *BLCU) serveDownloaded(w http.ResponseWriter, job string) {
+	image, ok := blcu.downloads.get(job)
+	if !ok {
+		http.Error(w, "download not found", http.StatusNotFound)
+		return
+	}
+
+	output := &streamWriter{
+		writer:   w,
+		filename: fmt.Sprintf("%s-%s.bin", image.board, job),
+	}
+	if _, err := output.Write(image.data); err != nil {
+		blcu.trace.Error().Err(err).Str("job", job).Msg("sending download")
+	}
+}
+
+// runHTTPJob queues the transfer and blocks until it is done or cancelled,
+// progress is only reported over the websocket
+func (blcu *BLCU) runHTTPJob(r *http.Request, kind string, board string, run func(job *job, onProgress func(float64))) {
+	finished := make(chan struct{})
+	job := newJob(kind, []string{board}, httpJobOwner, func(status JobStatus) {
+		blcu.progress.Next(&TransferProgress{Job: status.Job, Kind: kind, Board: board, Position: status.Position, State: status.State})
+		if status.State == DoneJobState || status.State == CancelledJobState {
+			close(finished)
+		}
+	}, func(job *job) {
+		run(job, func(percentage float64) {
+			blcu.progress.Next(&TransferProgress{Job: job.id, Kind: kind, Board: board, State: RunningJobState, Percentage: percentage})
+		})
+	})
+
+	blcu.queue.push(job)
+
+	select {
+	case <-finished:
+	case <-r.Context().Done():
+		blcu.trace.Warn().Str("job", job.id).Str("board", board).Msg("transfer request closed")
+		blcu.queue.cancel(job.id, httpJobOwner)
+		<-finished
+	}
+}
+
+func readFormFile(r *http.Request) ([]byte, error) {
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+// streamWriter delays the download headers until the first bytes arrive from the board
+type streamWriter struct {
+	writer   http.ResponseWriter
+	filename string
+	started  bool
+}
+
+func (stream *streamWriter) Write(p []byte) (int, error) {
+	if !stream.started {
+		stream.writer.Header().Set("Content-Type", "application/octet-stream")
+		stream.writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", stream.filename))
+		stream.started = true
+	}
+
+	n, err := stream.writer.Write(p)
+	if flusher, ok := stream.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return n, err
+}
@@ -0,0 +1,96 @@
+package blcu
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
+)
+
+type cancelRequest struct {
+	Job string `json:"job"`
+}
+
+func (blcu *BLCU) enqueueUpload(client wsModels.Client, payload json.RawMessage) {
+	var request uploadRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		blcu.trace.Error().Err(err).Stack().Msg("Unmarshal payload")
+		blcu.notifyUploadFailure(client, "", UploadReport{Error: err.Error()})
+		return
+	}
+
+	// a batch request flashes each board in order within the same job
+	items := request.Batch
+	if len(items) == 0 {
+		items = []uploadRequest{request}
+	}
+
+	boards := common.Map(items, func(item uploadRequest) string { return item.Board })
+	blcu.queue.push(newJob(UploadJobKind, boards, client.Id(), blcu.clientJobNotifier(client), func(job *job) {
+		for _, item := range items {
+			if job.isCancelled() {
+				blcu.notifyUploadFailure(client, item.Board, UploadReport{Artifact: item.Artifact, Error: "cancelled"})
+				continue
+			}
+
+			report, err := blcu.upload(job.ctx, item, func(progress float64) {
+				blcu.notifyUploadProgress(client, item.Board, progress)
+			})
+			if err != nil {
+				report.Error = err.Error()
+				blcu.notifyUploadFailure(client, item.Board, report)
+			} else {
+				blcu.notifyUploadSuccess(client, item.Board, report)
+			}
+			blcu.recordFlash(report)
+		}
+	}))
+}
+
+func (blcu *BLCU) enqueueDownload(client wsModels.Client, payload json.RawMessage) {
+	var request downloadRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		blcu.trace.Error().Err(err).Stack().Msg("Unmarshal payload")
+		blcu.notifyDownloadFailure(client)
+		return
+	}
+
+	blcu.queue.push(newJob(DownloadJobKind, []string{request.Board}, client.Id(), blcu.clientJobNotifier(client), func(job *job) {
+		buffer := &bytes.Buffer{}
+		err := blcu.download(job.ctx, request.Board, buffer, func(progress float64) {
+			blcu.notifyDownloadProgress(client, progress)
+		})
+		if err != nil {
+			blcu.notifyDownloadFailure(client)
+			return
+		}
+
+		blcu.downloads.add(job.id, downloadedImage{board: request.Board, data: buffer.Bytes()})
+		blcu.notifyDownloadSuccess(client, job.id)
+		if err := blcu.writeDownloadFile(request.Board, buffer.Bytes()); err != nil {
+			blcu.trace.Error().Err(err).Str("board", request.Board).Msg("writing download file")
+		}
+	}))
+}
+
+func (blcu *BLCU) cancelJob(client wsModels.Client, payload json.RawMessage) {
+	var request cancelRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		blcu.trace.Error().Err(err).Stack().Msg("Unmarshal payload")
+		return
+	}
+
+	if !blcu.queue.cancel(request.Job, client.Id()) {
+		blcu.trace.Warn().Str("job", request.Job).Str("client", client.Id()).Msg("job to cancel not found")
+	}
+}
+
+// clientJobNotifier sends the status of jobs requested over the websocket to the client that requested them
+func (blcu *BLCU) clientJobNotifier(client wsModels.Client) func(JobStatus) {
+	return func(status JobStatus) {
+		if err := client.WriteMessage(blcu.config.Topics.Queue, status); err != nil {
+			blcu.trace.Error().Err(err).Str("job", status.Job).Msg("notifying job status")
+		}
+	}
+}
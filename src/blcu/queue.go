@@ -0,0 +1,192 @@
+package blcu
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UploadJobKind   = "upload"
+	DownloadJobKind = "download"
+
+	QueuedJobState    = "queued"
+	RunningJobState   = "running"
+	DoneJobState      = "done"
+	CancelledJobState = "cancelled"
+)
+
+type JobStatus struct {
+	Job    string   `json:"job"`
+	Kind   string   `json:"kind"`
+	Boards []string `json:"boards"`
+	// Position is the number of jobs that will run before this one, 0 once it is running
+	Position int    `json:"position"`
+	State    string `json:"state"`
+}
+
+type job struct {
+	id     string
+	kind   string
+	boards []string
+	// owner is the only one allowed to cancel the job
+	owner string
+	// ctx is cancelled with the job, the transfers stop as soon as they see it
+	ctx    context.Context
+	cancel context.CancelFunc
+	notify func(status JobStatus)
+	run    func(job *job)
+}
+
+func newJob(kind string, boards []string, owner string, notify func(JobStatus), run func(job *job)) *job {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &job{
+		id:     uuid.NewString(),
+		kind:   kind,
+		boards: boards,
+		owner:  owner,
+		ctx:    ctx,
+		cancel: cancel,
+		notify: notify,
+		run:    run,
+	}
+}
+
+func (job *job) isCancelled() bool {
+	return job.ctx.Err() != nil
+}
+
+func (job *job) status(position int, state string) JobStatus {
+	return JobStatus{
+		Job:      job.id,
+		Kind:     job.kind,
+		Boards:   job.boards,
+		Position: position,
+		State:    state,
+	}
+}
+
+// notification is a job status sent once mx is released, so a slow client can't stall the queue
+type notification struct {
+	job    *job
+	status JobStatus
+}
+
+func send(notifications []notification) {
+	for _, notification := range notifications {
+		notification.job.notify(notification.status)
+	}
+}
+
+// jobQueue runs BLCU jobs one at a time so transfers never share the ack channel or the TFTP server
+type jobQueue struct {
+	mx      *sync.Mutex
+	pending []*job
+	current *job
+	wake    chan struct{}
+}
+
+func newJobQueue() *jobQueue {
+	return &jobQueue{
+		mx:      &sync.Mutex{},
+		pending: make([]*job, 0),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+func (queue *jobQueue) push(job *job) {
+	queue.mx.Lock()
+	queue.pending = append(queue.pending, job)
+	notifications := queue.positions()
+	queue.mx.Unlock()
+
+	send(notifications)
+
+	select {
+	case queue.wake <- struct{}{}:
+	default:
+	}
+}
+
+// cancel removes a pending job or cancels the context of the running one, which interrupts its transfer
+func (queue *jobQueue) cancel(id string, owner string) bool {
+	queue.mx.Lock()
+
+	if queue.current != nil && queue.current.id == id && queue.current.owner == owner {
+		queue.current.cancel()
+		queue.mx.Unlock()
+		return true
+	}
+
+	for i, pending := range queue.pending {
+		if pending.id == id && pending.owner == owner {
+			queue.pending = append(queue.pending[:i], queue.pending[i+1:]...)
+			pending.cancel()
+			notifications := append([]notification{{pending, pending.status(0, CancelledJobState)}}, queue.positions()...)
+			queue.mx.Unlock()
+
+			send(notifications)
+			return true
+		}
+	}
+
+	queue.mx.Unlock()
+	return false
+}
+
+func (queue *jobQueue) run() {
+	for {
+		job := queue.next()
+		if job == nil {
+			<-queue.wake
+			continue
+		}
+
+		job.run(job)
+
+		state := DoneJobState
+		if job.isCancelled() {
+			state = CancelledJobState
+		}
+		job.cancel()
+
+		queue.mx.Lock()
+		queue.current = nil
+		queue.mx.Unlock()
+
+		job.notify(job.status(0, state))
+	}
+}
+
+func (queue *jobQueue) next() *job {
+	queue.mx.Lock()
+
+	if len(queue.pending) == 0 {
+		queue.mx.Unlock()
+		return nil
+	}
+
+	current := queue.pending[0]
+	queue.current = current
+	queue.pending = queue.pending[1:]
+	notifications := append([]notification{{current, current.status(0, RunningJobState)}}, queue.positions()...)
+	queue.mx.Unlock()
+
+	send(notifications)
+	return current
+}
+
+// positions must be called with mx held, it returns the queued status of every pending job
+func (queue *jobQueue) positions() []notification {
+	offset := 0
+	if queue.current != nil {
+		offset = 1
+	}
+
+	notifications := make([]notification, 0, len(queue.pending))
+	for i, pending := range queue.pending {
+		notifications = append(notifications, notification{pending, pending.status(i+offset, QueuedJobState)})
+	}
+	return notifications
+}
@@ -2,53 +2,161 @@ package blcu
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"time"
 
-	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/HyperloopUPV-H8/Backend-H8/blcu/firmware"
 	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
 	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
-	"github.com/pin/tftp/v3"
 )
 
+// uploadRequest carries either the file or the id of a stored firmware artifact
 type uploadRequest struct {
-	Board string `json:"board"`
-	File  string `json:"file"`
+	Board    string `json:"board"`
+	File     string `json:"file,omitempty"`
+	Artifact string `json:"artifact,omitempty"`
+	// Batch lists several uploads to run one after the other
+	Batch []uploadRequest `json:"batch,omitempty"`
+
+	// data is the raw file when it was uploaded over HTTP
+	data []byte
 }
 
-func (blcu *BLCU) upload(client wsModels.Client, payload json.RawMessage) error {
+type UploadReport struct {
+	Artifact   string `json:"artifact,omitempty"`
+	Checksum   string `json:"checksum"`
+	DurationMs int64  `json:"durationMs"`
+	Attempts   int    `json:"attempts"`
+	// Verified is nil when read back verification is disabled
+	Verified *bool  `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (blcu *BLCU) upload(ctx context.Context, request uploadRequest, onProgress func(float64)) (UploadReport, error) {
 	blcu.trace.Debug().Msg("Handling upload")
+	start := time.Now()
 
-	var request uploadRequest
-	if err := json.Unmarshal(payload, &request); err != nil {
-		blcu.trace.Error().Err(err).Stack().Msg("Unmarshal payload")
-		return err
+	decoded, err := blcu.getUploadFile(request)
+	if err != nil {
+		blcu.trace.Error().Err(err).Stack().Msg("Get upload file")
+		return UploadReport{}, err
+	}
+
+	report := UploadReport{Artifact: request.Artifact, Checksum: firmware.Checksum(decoded)}
+	blcu.trace.Info().Str("board", request.Board).Str("checksum", report.Checksum).Int("size", len(decoded)).Msg("Uploading file")
+
+	for attempt := 1; attempt <= blcu.config.Retries+1; attempt++ {
+		report.Attempts = attempt
+		onProgress(0)
+		err = blcu.tryUpload(ctx, request.Board, decoded, onProgress)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+		blcu.trace.Warn().Err(err).Int("attempt", attempt).Msg("Upload attempt failed")
+	}
+
+	if err != nil {
+		report.DurationMs = time.Since(start).Milliseconds()
+		return report, err
+	}
+
+	if blcu.config.Verify {
+		verified, err := blcu.verifyUpload(ctx, request.Board, decoded)
+		report.Verified = &verified
+		report.DurationMs = time.Since(start).Milliseconds()
+		if err != nil {
+			return report, err
+		}
+
+		if !verified {
+			return report, fmt.Errorf("read back checksum does not match %s", report.Checksum)
+		}
 	}
 
-	blcu.notifyUploadProgress(client, 0)
+	report.DurationMs = time.Since(start).Milliseconds()
+	return report, nil
+}
 
-	if err := blcu.requestUpload(request.Board); err != nil {
+func (blcu *BLCU) tryUpload(ctx context.Context, board string, data []byte, onProgress func(float64)) error {
+	if err := blcu.requestUpload(ctx, board); err != nil {
 		blcu.trace.Error().Err(err).Stack().Msg("Request upload")
 		return err
 	}
 
-	decoded, err := base64.StdEncoding.DecodeString(request.File)
+	reader := bytes.NewReader(data)
+	return blcu.WriteTFTP(ctx, reader, int(reader.Size()), onProgress)
+}
+
+// verifyUpload downloads the board memory and compares it with the uploaded data,
+// only the first len(data) bytes are checked as the rest of the flash is not written
+func (blcu *BLCU) verifyUpload(ctx context.Context, board string, data []byte) (bool, error) {
+	blcu.trace.Info().Str("board", board).Msg("Verifying upload")
+
+	if err := blcu.requestDownload(ctx, board); err != nil {
+		return false, err
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := blcu.ReadTFTP(ctx, buffer, func(float64) {}); err != nil {
+		return false, err
+	}
+
+	readBack := buffer.Bytes()
+	if len(readBack) < len(data) {
+		return false, nil
+	}
+
+	return firmware.Checksum(readBack[:len(data)]) == firmware.Checksum(data), nil
+}
+
+func (blcu *BLCU) getUploadFile(request uploadRequest) ([]byte, error) {
+	if request.data != nil {
+		return request.data, nil
+	}
+
+	if request.Artifact == "" {
+		return base64.StdEncoding.DecodeString(request.File)
+	}
+
+	if blcu.firmware == nil {
+		return nil, errors.New("firmware store not configured")
+	}
+
+	artifact, data, err := blcu.firmware.Read(request.Artifact)
 	if err != nil {
-		blcu.trace.Error().Err(err).Stack().Msg("Decode payload")
-		return err
+		return nil, err
+	}
+
+	if artifact.Board != request.Board {
+		return nil, fmt.Errorf("artifact %s is for board %s, not %s", artifact.Id, artifact.Board, request.Board)
+	}
+
+	return data, nil
+}
+
+// recordFlash adds the result of the upload to the history of the flashed artifact
+func (blcu *BLCU) recordFlash(report UploadReport) {
+	if report.Artifact == "" || blcu.firmware == nil {
+		return
 	}
 
-	reader := bytes.NewReader(decoded)
-	return blcu.WriteTFTP(reader, int(reader.Size()), func(progress float64) {
-		blcu.notifyUploadProgress(client, progress)
+	err := blcu.firmware.RecordFlash(report.Artifact, firmware.FlashRecord{
+		Timestamp: time.Now(),
+		Success:   report.Error == "",
+		Verified:  report.Verified,
+		Error:     report.Error,
 	})
+
+	if err != nil {
+		blcu.trace.Error().Err(err).Str("artifact", report.Artifact).Msg("recording flash")
+	}
 }
 
-func (blcu *BLCU) requestUpload(board string) error {
+func (blcu *BLCU) requestUpload(ctx context.Context, board string) error {
 	blcu.trace.Info().Str("board", board).Msg("Requesting upload")
 
 	uploadOrder, err := blcu.createUploadOrder(board)
@@ -56,16 +164,8 @@ func (blcu *BLCU) requestUpload(board string) error {
 	if err != nil {
 		return err
 	}
-	if err := blcu.sendOrder(uploadOrder); err != nil {
-		return err
-	}
-
-	// TODO: remove hardcoded timeout
-	if _, err := common.ReadTimeout(blcu.ackChannel, time.Second*10); err != nil {
-		return err
-	}
 
-	return nil
+	return blcu.waitAck(ctx, uploadOrder)
 }
 
 func (blcu *BLCU) createUploadOrder(board string) (models.Order, error) {
@@ -87,10 +187,11 @@ func (blcu *BLCU) createUploadOrder(board string) (models.Order, error) {
 	}, nil
 }
 
-func (blcu *BLCU) WriteTFTP(reader io.Reader, size int, onProgress func(float64)) error {
+// WriteTFTP stops the transfer with the context error once ctx is cancelled
+func (blcu *BLCU) WriteTFTP(ctx context.Context, reader io.Reader, size int, onProgress func(float64)) error {
 	blcu.trace.Info().Msg("Writing TFTP")
 
-	client, err := tftp.NewClient(blcu.addr.String())
+	client, err := blcu.newTFTPClient()
 	if err != nil {
 		blcu.trace.Error().Err(err).Str("client", blcu.addr.String()).Msg("creating client")
 		return err
@@ -102,58 +203,41 @@ func (blcu *BLCU) WriteTFTP(reader io.Reader, size int, onProgress func(float64)
 		return err
 	}
 
-	upload := NewUpload(reader, size, onProgress)
+	upload := NewUpload(ctx, reader, size, onProgress)
 	_, err = sender.ReadFrom(&upload)
 
 	return err
 }
 
 type uploadResponse struct {
-	Percentage float64 `json:"percentage"`
-	Failure    bool    `json:"failure"`
+	Board      string        `json:"board,omitempty"`
+	Percentage float64       `json:"percentage"`
+	Failure    bool          `json:"failure"`
+	Report     *UploadReport `json:"report,omitempty"`
 }
 
-func (blcu *BLCU) notifyUploadFailure(client wsModels.Client) {
-	blcu.trace.Warn().Msg("Upload failed")
+func (blcu *BLCU) notifyUploadFailure(client wsModels.Client, board string, report UploadReport) {
+	blcu.trace.Warn().Str("board", board).Str("error", report.Error).Msg("Upload failed")
 
-	msgBuf, err := wsModels.NewMessageBuf(blcu.config.Topics.Upload, uploadResponse{Percentage: 0, Failure: true})
-
-	if err != nil {
-		return
-	}
-
-	err = client.Write(msgBuf)
+	err := client.WriteMessage(blcu.config.Topics.Upload, uploadResponse{Board: board, Percentage: 0, Failure: true, Report: &report})
 	//TODO: handle error
 	if err != nil {
 		return
 	}
 }
 
-func (blcu *BLCU) notifyUploadSuccess(client wsModels.Client) {
-	blcu.trace.Info().Msg("Upload success")
-
-	msgBuf, err := wsModels.NewMessageBuf(blcu.config.Topics.Upload, uploadResponse{Percentage: 100, Failure: false})
-
-	if err != nil {
-		return
-	}
+func (blcu *BLCU) notifyUploadSuccess(client wsModels.Client, board string, report UploadReport) {
+	blcu.trace.Info().Str("board", board).Str("checksum", report.Checksum).Int64("durationMs", report.DurationMs).Msg("Upload success")
 
-	err = client.Write(msgBuf)
+	err := client.WriteMessage(blcu.config.Topics.Upload, uploadResponse{Board: board, Percentage: 100, Failure: false, Report: &report})
 	//TODO: handle error
 	if err != nil {
 		return
 	}
 }
 
-func (blcu *BLCU) notifyUploadProgress(client wsModels.Client, percentage float64) {
-	msgBuf, err := wsModels.NewMessageBuf(blcu.config.Topics.Upload, uploadResponse{Percentage: percentage, Failure: false})
-
-	if err != nil {
-		blcu.trace.Error().Err(err).Msg("creating upload progress message buf")
-		return
-	}
-
-	err = client.Write(msgBuf)
+func (blcu *BLCU) notifyUploadProgress(client wsModels.Client, board string, percentage float64) {
+	err := client.WriteMessage(blcu.config.Topics.Upload, uploadResponse{Board: board, Percentage: percentage, Failure: false})
 
 	//TODO: handle error
 	if err != nil {
@@ -163,14 +247,16 @@ func (blcu *BLCU) notifyUploadProgress(client wsModels.Client, percentage float6
 }
 
 type Upload struct {
+	ctx        context.Context
 	reader     io.Reader
 	onProgress func(float64)
 	total      int
 	current    int
 }
 
-func NewUpload(reader io.Reader, size int, onProgress func(float64)) Upload {
+func NewUpload(ctx context.Context, reader io.Reader, size int, onProgress func(float64)) Upload {
 	return Upload{
+		ctx:        ctx,
 		reader:     reader,
 		onProgress: onProgress,
 		total:      size,
@@ -179,6 +265,10 @@ func NewUpload(reader io.Reader, size int, onProgress func(float64)) Upload {
 }
 
 func (upload *Upload) Read(p []byte) (n int, err error) {
+	if err := upload.ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	n, err = upload.reader.Read(p)
 	if err == nil || errors.Is(err, io.EOF) {
 		upload.current += n
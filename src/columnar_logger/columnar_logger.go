@@ -0,0 +1,220 @@
+package columnar_logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
+	"github.com/HyperloopUPV-H8/Backend-H8/pod_data"
+	"github.com/parquet-go/parquet-go"
+	"github.com/rs/zerolog"
+	trace "github.com/rs/zerolog/log"
+)
+
+const TimestampColumn = "timestamp"
+
+type Config struct {
+	// Enable adds the columnar logger to the session loggers
+	Enable        bool   `toml:"enable"`
+	FolderName    string `toml:"folder_name"`
+	FlushInterval string `toml:"flush_interval"`
+}
+
+// ColumnarLogger writes the values of each packet into its own parquet file,
+// one typed column per measurement and a nanosecond timestamp column
+type ColumnarLogger struct {
+	ids           common.Set[string]
+	packets       map[uint16]packetSchema
+	flushInterval time.Duration
+	folderName    string
+	trace         zerolog.Logger
+}
+
+type packetSchema struct {
+	board  string
+	name   string
+	schema *parquet.Schema
+	units  map[string]string
+}
+
+func NewColumnarLogger(boards []pod_data.Board, config Config) ColumnarLogger {
+	trace := trace.With().Str("component", "columnarLogger").Logger()
+
+	flushInterval, err := time.ParseDuration(config.FlushInterval)
+
+	if err != nil {
+		trace.Fatal().Err(err).Str("flushInterval", config.FlushInterval).Msg("error parsing flush duration")
+	}
+
+	ids := common.NewSet[string]()
+	packets := make(map[uint16]packetSchema)
+	for _, board := range boards {
+		for _, packet := range board.Packets {
+			if len(packet.Measurements) == 0 {
+				continue
+			}
+
+			ids.Add(LoggableId(packet.Id))
+			packets[packet.Id] = newPacketSchema(board.Name, packet)
+		}
+	}
+
+	return ColumnarLogger{
+		ids:           ids,
+		packets:       packets,
+		flushInterval: flushInterval,
+		folderName:    config.FolderName,
+		trace:         trace,
+	}
+}
+
+func newPacketSchema(board string, packet pod_data.Packet) packetSchema {
+	group := parquet.Group{TimestampColumn: parquet.Timestamp(parquet.Nanosecond)}
+	units := make(map[string]string)
+
+	for _, measurement := range packet.Measurements {
+		switch measurement := measurement.(type) {
+		case pod_data.NumericMeasurement:
+			group[measurement.Id] = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+			units[measurement.Id] = measurement.Units
+		case pod_data.BooleanMeasurement:
+			group[measurement.Id] = parquet.Optional(parquet.Leaf(parquet.BooleanType))
+		default:
+			group[measurement.GetId()] = parquet.Optional(parquet.String())
+		}
+	}
+
+	return packetSchema{
+		board:  board,
+		name:   packet.Name,
+		schema: parquet.NewSchema(packet.Name, group),
+		units:  units,
+	}
+}
+
+func (cl *ColumnarLogger) Ids() common.Set[string] {
+	return cl.ids
+}
+
+func (cl *ColumnarLogger) Start(basePath string) chan<- logger_handler.Loggable {
+	loggableChan := make(chan logger_handler.Loggable)
+
+	go cl.startLoggingRoutine(loggableChan, filepath.Join(basePath, cl.folderName))
+
+	return loggableChan
+}
+
+type packetFile struct {
+	file   *os.File
+	writer *parquet.Writer
+}
+
+func (cl *ColumnarLogger) startLoggingRoutine(loggableChan <-chan logger_handler.Loggable, path string) {
+	files := make(map[uint16]packetFile)
+	filesMx := &sync.Mutex{}
+	flushTicker := time.NewTicker(cl.flushInterval)
+	done := make(chan struct{})
+
+	go cl.startFlushRoutine(flushTicker.C, files, filesMx, done)
+
+	for loggable := range loggableChan {
+		values, ok := loggable.(LoggablePacketValues)
+		if !ok {
+			continue
+		}
+
+		filesMx.Lock()
+		file, err := cl.getOrAddFile(files, path, values.PacketId)
+		if err == nil {
+			err = file.writer.Write(cl.toRow(values))
+		}
+		filesMx.Unlock()
+
+		if err != nil {
+			cl.trace.Error().Err(err).Uint16("packet", values.PacketId).Msg("writing row")
+		}
+	}
+
+	done <- struct{}{}
+	flushTicker.Stop()
+
+	for id, file := range files {
+		if err := file.close(); err != nil {
+			cl.trace.Error().Err(err).Uint16("packet", id).Msg("error closing file")
+		}
+	}
+}
+
+func (cl *ColumnarLogger) toRow(values LoggablePacketValues) map[string]any {
+	row := map[string]any{TimestampColumn: values.Timestamp.UnixNano()}
+	for id, value := range values.Values {
+		row[id] = value.Inner()
+	}
+	return row
+}
+
+func (cl *ColumnarLogger) getOrAddFile(files map[uint16]packetFile, path string, id uint16) (packetFile, error) {
+	if file, ok := files[id]; ok {
+		return file, nil
+	}
+
+	schema, ok := cl.packets[id]
+	if !ok {
+		return packetFile{}, fmt.Errorf("missing schema for packet %d", id)
+	}
+
+	if err := os.MkdirAll(path, 0777); err != nil {
+		return packetFile{}, err
+	}
+
+	name := filepath.Join(path, fmt.Sprintf("%d.parquet", id))
+	file, err := os.Create(name)
+	if err != nil {
+		return packetFile{}, err
+	}
+	os.Chmod(name, 0777)
+
+	writer := parquet.NewWriter(file, schema.schema)
+	writer.SetKeyValueMetadata("board", schema.board)
+	writer.SetKeyValueMetadata("packet", schema.name)
+	writer.SetKeyValueMetadata("id", strconv.Itoa(int(id)))
+	for measurement, units := range schema.units {
+		writer.SetKeyValueMetadata("units."+measurement, units)
+	}
+
+	files[id] = packetFile{file: file, writer: writer}
+	return files[id], nil
+}
+
+func (cl *ColumnarLogger) startFlushRoutine(tickerChan <-chan time.Time, files map[uint16]packetFile, filesMx *sync.Mutex, done chan struct{}) {
+loop:
+	for {
+		select {
+		case <-tickerChan:
+			filesMx.Lock()
+			for id, file := range files {
+				// flushing writes the buffered rows as a row group, keeping memory bounded
+				if err := file.writer.Flush(); err != nil {
+					cl.trace.Error().Err(err).Uint16("packet", id).Msg("error flushing file")
+				}
+			}
+			filesMx.Unlock()
+		case <-done:
+			break loop
+		}
+	}
+}
+
+func (file packetFile) close() error {
+	if err := file.writer.Close(); err != nil {
+		file.file.Close()
+		return err
+	}
+
+	return file.file.Close()
+}
@@ -0,0 +1,48 @@
+package columnar_logger
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/packet"
+	vehicle_models "github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+)
+
+// LoggablePacketValues carries the decoded values of a packet so they can be stored as a row
+type LoggablePacketValues struct {
+	PacketId  uint16
+	Timestamp time.Time
+	Values    map[string]packet.Value
+}
+
+func LoggableId(packetId uint16) string {
+	return fmt.Sprintf("%d/values", packetId)
+}
+
+func (values LoggablePacketValues) Id() string {
+	return LoggableId(values.PacketId)
+}
+
+func (values LoggablePacketValues) Log() []string {
+	ids := make([]string, 0, len(values.Values))
+	for id := range values.Values {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	row := []string{fmt.Sprint(values.Timestamp.UnixNano())}
+	for _, id := range ids {
+		row = append(row, fmt.Sprintf("%s=%v", id, values.Values[id].Inner()))
+	}
+
+	return row
+}
+
+func ToLoggablePacketValues(update vehicle_models.PacketUpdate) LoggablePacketValues {
+	return LoggablePacketValues{
+		PacketId:  update.Metadata.ID,
+		Timestamp: update.Metadata.Timestamp,
+		Values:    update.Values,
+	}
+}
@@ -0,0 +1,12 @@
+package common
+
+import "time"
+
+// Bucket summarizes the values of a measurement from Timestamp until the next bucket
+type Bucket struct {
+	Timestamp time.Time `json:"timestamp"`
+	Min       float64   `json:"min"`
+	Max       float64   `json:"max"`
+	Mean      float64   `json:"mean"`
+	Count     int64     `json:"count"`
+}
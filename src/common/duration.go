@@ -0,0 +1,21 @@
+package common
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ParseDuration parses a duration from the config, using fallback when it is empty, and stops the backend if it is invalid
+func ParseDuration(value string, fallback string, trace zerolog.Logger) time.Duration {
+	if value == "" {
+		value = fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		trace.Fatal().Err(err).Str("duration", value).Msg("error parsing duration")
+	}
+
+	return duration
+}
@@ -0,0 +1,39 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrorStatus maps the errors matching Err to an HTTP status, Message replaces the error text when set
+type ErrorStatus struct {
+	Err     error
+	Status  int
+	Message string
+}
+
+// WriteJSON sends v as the JSON body of the response
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// WriteError sends err with the status of the first entry it matches, or 500 when none does
+func WriteError(w http.ResponseWriter, err error, statuses []ErrorStatus) {
+	for _, status := range statuses {
+		if !errors.Is(err, status.Err) {
+			continue
+		}
+
+		message := status.Message
+		if message == "" {
+			message = err.Error()
+		}
+		http.Error(w, message, status.Status)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
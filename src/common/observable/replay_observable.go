@@ -38,7 +38,10 @@ func (o *ReplayObservable[T]) Subscribe(observer Observer[T]) {
 	observer.Next(o.last)
 }
 
+// Unsubscribe can't be called from an observer Next, which runs with observerMx held
 func (o *ReplayObservable[T]) Unsubscribe(id string) {
+	o.observerMx.Lock()
+	defer o.observerMx.Unlock()
 	delete(o.observers, id)
 }
 
@@ -0,0 +1,50 @@
+package observable
+
+import "sync"
+
+// SnapshotObservable sends the values returned by snapshot to each new observer before any broadcasted value,
+// so late subscribers start from the current state instead of the last value alone
+type SnapshotObservable[T any] struct {
+	snapshot func() []T
+
+	observers  map[string]Observer[T]
+	observerMx *sync.Mutex
+}
+
+func NewSnapshotObservable[T any](snapshot func() []T) SnapshotObservable[T] {
+	return SnapshotObservable[T]{
+		snapshot: snapshot,
+
+		observers:  make(map[string]Observer[T], 0),
+		observerMx: &sync.Mutex{},
+	}
+}
+
+func (o *SnapshotObservable[T]) Next(value T) {
+	o.broadcast(value)
+}
+
+func (o *SnapshotObservable[T]) Subscribe(observer Observer[T]) {
+	o.observerMx.Lock()
+	defer o.observerMx.Unlock()
+	o.observers[observer.Id()] = observer
+
+	for _, value := range o.snapshot() {
+		observer.Next(value)
+	}
+}
+
+// Unsubscribe can't be called from an observer Next, which runs with observerMx held
+func (o *SnapshotObservable[T]) Unsubscribe(id string) {
+	o.observerMx.Lock()
+	defer o.observerMx.Unlock()
+	delete(o.observers, id)
+}
+
+func (o *SnapshotObservable[T]) broadcast(value T) {
+	o.observerMx.Lock()
+	defer o.observerMx.Unlock()
+	for _, observer := range o.observers {
+		observer.Next(value)
+	}
+}
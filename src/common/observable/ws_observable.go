@@ -28,22 +28,11 @@ func HandleSubscribe[T any](obs Observable[T], msg wsModels.Message, client wsMo
 
 func addWsObserver[T any](obs Observable[T], id string, topic string, client wsModels.Client) {
 	observer := NewWsObserver(id, func(v T) {
-		msg, err := wsModels.NewMessage(topic, v)
+		err := client.WriteMessage(topic, v)
 
+		// the observer is removed once the broadcast that failed releases the observable
 		if err != nil {
-			return
-		}
-
-		msgBuf, err := json.Marshal(msg)
-
-		if err != nil {
-			return
-		}
-
-		err = client.Write(msgBuf)
-
-		if err != nil {
-			obs.Unsubscribe(id)
+			go obs.Unsubscribe(id)
 		}
 	})
 
@@ -63,3 +63,10 @@ func (buf *RingBuf[T]) Grow(amount uint) []T {
 
 	return added
 }
+
+// Items returns the values from the oldest to the newest, including the zero values of the slots not filled yet
+func (buf *RingBuf[T]) Items() []T {
+	items := make([]T, 0, buf.Len())
+	items = append(items, buf.buf[buf.curr:]...)
+	return append(items, buf.buf[:buf.curr]...)
+}
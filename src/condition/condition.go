@@ -0,0 +1,76 @@
+package condition
+
+import (
+	"fmt"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/packet"
+)
+
+type Condition struct {
+	Measurement string `json:"measurement" toml:"measurement"`
+	Comparator  string `json:"comparator" toml:"comparator"`
+	Value       any    `json:"value" toml:"value"`
+}
+
+func (condition Condition) String() string {
+	return fmt.Sprintf("%s %s %v", condition.Measurement, condition.Comparator, condition.Value)
+}
+
+func (condition Condition) Evaluate(value packet.Value) (bool, error) {
+	switch typedValue := value.(type) {
+	case packet.Numeric:
+		want, ok := toFloat(condition.Value)
+		if !ok {
+			return false, fmt.Errorf("expected numeric value for %s, got %T", condition.Measurement, condition.Value)
+		}
+		return compareNumeric(float64(typedValue), condition.Comparator, want)
+	case packet.Boolean, packet.Enum:
+		return compareEquality(typedValue.Inner(), condition.Comparator, condition.Value)
+	default:
+		return false, fmt.Errorf("unsupported value type %T for %s", value, condition.Measurement)
+	}
+}
+
+// toFloat accepts the integers produced when decoding the value from toml
+func toFloat(value any) (float64, bool) {
+	switch typedValue := value.(type) {
+	case float64:
+		return typedValue, true
+	case int64:
+		return float64(typedValue), true
+	case int:
+		return float64(typedValue), true
+	default:
+		return 0, false
+	}
+}
+
+func compareNumeric(got float64, comparator string, want float64) (bool, error) {
+	switch comparator {
+	case "==":
+		return got == want, nil
+	case "!=":
+		return got != want, nil
+	case "<":
+		return got < want, nil
+	case "<=":
+		return got <= want, nil
+	case ">":
+		return got > want, nil
+	case ">=":
+		return got >= want, nil
+	default:
+		return false, fmt.Errorf("unknown comparator %s", comparator)
+	}
+}
+
+func compareEquality(got any, comparator string, want any) (bool, error) {
+	switch comparator {
+	case "==":
+		return got == want, nil
+	case "!=":
+		return got != want, nil
+	default:
+		return false, fmt.Errorf("comparator %s not supported for non numeric values", comparator)
+	}
+}
@@ -0,0 +1,44 @@
+package condition
+
+import (
+	"testing"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/packet"
+)
+
+func TestCondition(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		value     packet.Value
+		want      bool
+	}{
+		{"numeric greater", Condition{"m", ">", 5.0}, packet.Numeric(6), true},
+		{"numeric from toml integer", Condition{"m", "<=", int64(5)}, packet.Numeric(5), true},
+		{"numeric not equal", Condition{"m", "!=", 5.0}, packet.Numeric(5), false},
+		{"boolean equal", Condition{"m", "==", true}, packet.Boolean(true), true},
+		{"enum different", Condition{"m", "!=", "RUNNING"}, packet.Enum("IDLE"), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.condition.Evaluate(test.value)
+
+			if err != nil {
+				t.Fatalf("evaluating condition: %s", err)
+			}
+
+			if got != test.want {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+
+	t.Run("enum rejects ordering comparators", func(t *testing.T) {
+		_, err := Condition{"m", ">", "IDLE"}.Evaluate(packet.Enum("IDLE"))
+
+		if err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+}
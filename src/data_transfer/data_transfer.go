@@ -1,6 +1,7 @@
 package data_transfer
 
 import (
+	"encoding/json"
 	"sync"
 	"time"
 
@@ -20,8 +21,17 @@ const (
 type DataTransfer struct {
 	bufMx            *sync.Mutex
 	updateBuf        map[uint16]models.Update
-	updateObservable observable.ReplayObservable[map[uint16]models.Update]
+	updateObservable observable.SnapshotObservable[map[uint16]models.Update]
 	ticker           *time.Ticker
+	fps              uint
+	// state holds the last update of every packet, it is sent to new subscribers and used for the delta keyframes.
+	// stateMx is taken after bufMx
+	stateMx          *sync.Mutex
+	state            map[uint16]models.Update
+	deltaMx          *sync.Mutex
+	deltaObservers   map[string]*filteredObserver
+	delta            DeltaConfig
+	keyframeInterval time.Duration
 	updateTopic      string
 	trace            zerolog.Logger
 }
@@ -31,19 +41,38 @@ type DataTransferTopics struct {
 type DataTransferConfig struct {
 	Fps    uint
 	Topics DataTransferTopics
+	Delta  DeltaConfig `toml:"delta"`
 }
 
 func New(config DataTransferConfig) DataTransfer {
 	trace.Info().Msg("create data transfer")
+	dataTrace := trace.With().Str("component", DataTransferHandlerName).Logger()
+
+	keyframeInterval := config.Delta.KeyframeInterval
+	if keyframeInterval == "" {
+		keyframeInterval = DEFAULT_KEYFRAME_INTERVAL
+	}
+
+	interval, err := time.ParseDuration(keyframeInterval)
+	if err != nil {
+		dataTrace.Fatal().Err(err).Str("keyframeInterval", keyframeInterval).Msg("error parsing keyframe interval")
+	}
 
 	dataTransfer := DataTransfer{
 		bufMx:            &sync.Mutex{},
 		updateBuf:        make(map[uint16]models.Update),
-		updateObservable: observable.NewReplayObservable(make(map[uint16]models.Update)),
 		ticker:           time.NewTicker(time.Second / time.Duration(config.Fps)),
+		fps:              config.Fps,
+		stateMx:          &sync.Mutex{},
+		state:            make(map[uint16]models.Update),
+		deltaMx:          &sync.Mutex{},
+		deltaObservers:   make(map[string]*filteredObserver),
+		delta:            config.Delta,
+		keyframeInterval: interval,
 		updateTopic:      config.Topics.Update,
-		trace:            trace.With().Str("component", DataTransferHandlerName).Logger(),
+		trace:            dataTrace,
 	}
+	dataTransfer.updateObservable = observable.NewSnapshotObservable(dataTransfer.subscribeSnapshot)
 
 	return dataTransfer
 }
@@ -51,7 +80,13 @@ func New(config DataTransferConfig) DataTransfer {
 func (dataTransfer *DataTransfer) UpdateMessage(client wsModels.Client, msg wsModels.Message) {
 	dataTransfer.trace.Info().Str("source", client.Id()).Str("topic", msg.Topic).Msg("got message")
 
-	observable.HandleSubscribe[map[uint16]models.Update](&dataTransfer.updateObservable, msg, client)
+	var subscription UpdateSubscription
+	if err := json.Unmarshal(msg.Payload, &subscription); err != nil {
+		dataTransfer.trace.Error().Err(err).Msg("unmarshal subscription")
+		return
+	}
+
+	dataTransfer.handleSubscription(subscription, client, msg.Topic)
 }
 
 func (DataTransfer *DataTransfer) HandlerName() string {
@@ -62,18 +97,16 @@ func (dataTransfer *DataTransfer) Run() {
 	dataTransfer.trace.Info().Msg("run")
 	for {
 		<-dataTransfer.ticker.C
-		dataTransfer.trySend()
+		dataTransfer.tick()
 	}
 }
 
-func (dataTransfer *DataTransfer) trySend() {
+// tick sends the buffer even when it is empty, the observers count the ticks to pace their writes
+// and the delta keyframes and resyncs must not wait for the next packet
+func (dataTransfer *DataTransfer) tick() {
 	dataTransfer.bufMx.Lock()
 	defer dataTransfer.bufMx.Unlock()
 
-	if len(dataTransfer.updateBuf) == 0 {
-		return
-	}
-
 	dataTransfer.sendBuf()
 }
 
@@ -88,4 +121,29 @@ func (dataTransfer *DataTransfer) Update(update models.Update) {
 	defer dataTransfer.bufMx.Unlock()
 	dataTransfer.trace.Trace().Uint16("id", update.Id).Msg("update")
 	dataTransfer.updateBuf[update.Id] = update
+
+	dataTransfer.stateMx.Lock()
+	dataTransfer.state[update.Id] = update
+	dataTransfer.stateMx.Unlock()
+}
+
+// subscribeSnapshot is the first update of a new subscriber, empty until any packet arrives
+func (dataTransfer *DataTransfer) subscribeSnapshot() []map[uint16]models.Update {
+	state := dataTransfer.snapshot()
+	if len(state) == 0 {
+		return nil
+	}
+	return []map[uint16]models.Update{state}
+}
+
+// snapshot copies the last update of every packet
+func (dataTransfer *DataTransfer) snapshot() map[uint16]models.Update {
+	dataTransfer.stateMx.Lock()
+	defer dataTransfer.stateMx.Unlock()
+
+	state := make(map[uint16]models.Update, len(dataTransfer.state))
+	for id, update := range dataTransfer.state {
+		state[id] = update
+	}
+	return state
 }
@@ -0,0 +1,141 @@
+package data_transfer
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/update_factory/models"
+)
+
+const DEFAULT_KEYFRAME_INTERVAL = "5s"
+
+// DeltaConfig sets up the delta protocol used by the subscriptions that ask for it
+type DeltaConfig struct {
+	KeyframeInterval string `toml:"keyframe_interval"`
+	// Deadband is the minimum change of a numeric value to be sent, Deadbands overrides it per measurement
+	Deadband  float64            `toml:"deadband"`
+	Deadbands map[string]float64 `toml:"deadbands"`
+}
+
+// DeltaFrame is written to the delta subscriptions instead of the update map.
+// Keyframes carry the whole state, the frames in between only the values that changed beyond the deadband.
+// Seq grows by one on each frame, a client that sees a gap should ask for a resync
+type DeltaFrame struct {
+	Seq      uint64                 `json:"seq"`
+	Keyframe bool                   `json:"keyframe"`
+	Packets  map[uint16]PacketDelta `json:"packets"`
+}
+
+// PacketDelta is an Update without the hex value outside keyframes
+type PacketDelta struct {
+	Id        uint16                        `json:"id"`
+	HexValue  string                        `json:"hexValue,omitempty"`
+	Count     uint64                        `json:"count"`
+	CycleTime uint64                        `json:"cycleTime"`
+	Values    map[string]models.UpdateValue `json:"measurementUpdates"`
+}
+
+// deltaEncoder keeps what was sent to one client, it is only used from the observer Next
+type deltaEncoder struct {
+	seq uint64
+	// keyframeEvery is the number of frames between keyframes, a frame is built on every client tick even without updates
+	keyframeEvery uint
+	sinceKeyframe uint
+	resync        *atomic.Bool
+	sent          map[string]models.UpdateValue
+	config        DeltaConfig
+	state         func() map[uint16]models.Update
+}
+
+func newDeltaEncoder(config DeltaConfig, keyframeInterval time.Duration, frameInterval time.Duration, state func() map[uint16]models.Update) *deltaEncoder {
+	keyframeEvery := uint(keyframeInterval / frameInterval)
+	if keyframeEvery < 1 {
+		keyframeEvery = 1
+	}
+
+	encoder := &deltaEncoder{
+		keyframeEvery: keyframeEvery,
+		resync:        &atomic.Bool{},
+		sent:          make(map[string]models.UpdateValue),
+		config:        config,
+		state:         state,
+	}
+	// the first frame is always a keyframe
+	encoder.resync.Store(true)
+
+	return encoder
+}
+
+// frame builds the next frame from the updates buffered since the last one, it reports false when there is nothing to send
+func (encoder *deltaEncoder) frame(updates map[uint16]models.Update, filter func(models.Update) (models.Update, bool)) (DeltaFrame, bool) {
+	encoder.sinceKeyframe++
+	if encoder.resync.Swap(false) || encoder.sinceKeyframe >= encoder.keyframeEvery {
+		return encoder.keyframe(filter), true
+	}
+
+	packets := make(map[uint16]PacketDelta)
+	for id, update := range updates {
+		values := make(map[string]models.UpdateValue)
+		for measurement, value := range update.Values {
+			if encoder.changed(measurement, value) {
+				values[measurement] = value
+				encoder.sent[measurement] = value
+			}
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		packets[id] = PacketDelta{Id: update.Id, Count: update.Count, CycleTime: update.CycleTime, Values: values}
+	}
+
+	if len(packets) == 0 {
+		return DeltaFrame{}, false
+	}
+
+	encoder.seq++
+	return DeltaFrame{Seq: encoder.seq, Packets: packets}, true
+}
+
+func (encoder *deltaEncoder) keyframe(filter func(models.Update) (models.Update, bool)) DeltaFrame {
+	encoder.sinceKeyframe = 0
+	encoder.sent = make(map[string]models.UpdateValue, len(encoder.sent))
+
+	packets := make(map[uint16]PacketDelta)
+	for id, update := range encoder.state() {
+		update, ok := filter(update)
+		if !ok {
+			continue
+		}
+
+		for measurement, value := range update.Values {
+			encoder.sent[measurement] = value
+		}
+		packets[id] = PacketDelta{Id: update.Id, HexValue: update.HexValue, Count: update.Count, CycleTime: update.CycleTime, Values: update.Values}
+	}
+
+	encoder.seq++
+	return DeltaFrame{Seq: encoder.seq, Keyframe: true, Packets: packets}
+}
+
+func (encoder *deltaEncoder) changed(measurement string, value models.UpdateValue) bool {
+	last, ok := encoder.sent[measurement]
+	if !ok {
+		return true
+	}
+
+	numeric, isNumeric := value.(models.NumericValue)
+	lastNumeric, wasNumeric := last.(models.NumericValue)
+	if !isNumeric || !wasNumeric {
+		return value != last
+	}
+
+	deadband, ok := encoder.config.Deadbands[measurement]
+	if !ok {
+		deadband = encoder.config.Deadband
+	}
+
+	return math.Abs(numeric.Value-lastNumeric.Value) > deadband
+}
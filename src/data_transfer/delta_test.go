@@ -0,0 +1,144 @@
+package data_transfer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/update_factory/models"
+)
+
+func allUpdates(update models.Update) (models.Update, bool) {
+	return update, true
+}
+
+func TestDeltaEncoderChanged(t *testing.T) {
+	config := DeltaConfig{Deadband: 0.5, Deadbands: map[string]float64{"precise": 0}}
+
+	tests := []struct {
+		name        string
+		measurement string
+		sent        models.UpdateValue
+		value       models.UpdateValue
+		want        bool
+	}{
+		{"never sent", "m", nil, models.NumericValue{Value: 1}, true},
+		{"numeric inside deadband", "m", models.NumericValue{Value: 1}, models.NumericValue{Value: 1.4}, false},
+		{"numeric beyond deadband", "m", models.NumericValue{Value: 1}, models.NumericValue{Value: 1.6}, true},
+		{"numeric below deadband", "m", models.NumericValue{Value: 1}, models.NumericValue{Value: 0.4}, true},
+		{"measurement deadband", "precise", models.NumericValue{Value: 1}, models.NumericValue{Value: 1.1}, true},
+		{"same boolean", "m", models.BooleanValue(true), models.BooleanValue(true), false},
+		{"different enum", "m", models.EnumValue("IDLE"), models.EnumValue("RUNNING"), true},
+		{"kind changes", "m", models.NumericValue{Value: 1}, models.EnumValue("IDLE"), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoder := newDeltaEncoder(config, time.Second, time.Second, nil)
+			if test.sent != nil {
+				encoder.sent[test.measurement] = test.sent
+			}
+
+			if got := encoder.changed(test.measurement, test.value); got != test.want {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDeltaEncoderFrame(t *testing.T) {
+	state := map[uint16]models.Update{
+		1: {Id: 1, HexValue: "0a", Values: map[string]models.UpdateValue{
+			"a": models.NumericValue{Value: 1},
+			"b": models.BooleanValue(false),
+		}},
+	}
+
+	update := func(values map[string]models.UpdateValue) map[uint16]models.Update {
+		return map[uint16]models.Update{1: {Id: 1, HexValue: "0b", Values: values}}
+	}
+
+	// newEncoder sends the first keyframe so each test starts in the middle of the stream
+	newEncoder := func(keyframeEvery time.Duration) *deltaEncoder {
+		encoder := newDeltaEncoder(DeltaConfig{Deadband: 0.5}, keyframeEvery, time.Second, func() map[uint16]models.Update { return state })
+		encoder.frame(nil, allUpdates)
+		return encoder
+	}
+
+	t.Run("first frame is a keyframe", func(t *testing.T) {
+		encoder := newDeltaEncoder(DeltaConfig{}, time.Minute, time.Second, func() map[uint16]models.Update { return state })
+
+		frame, ok := encoder.frame(nil, allUpdates)
+		if !ok || !frame.Keyframe || frame.Seq != 1 {
+			t.Fatalf("expected keyframe 1, got %+v", frame)
+		}
+		if frame.Packets[1].HexValue != "0a" || len(frame.Packets[1].Values) != 2 {
+			t.Fatalf("expected the whole state, got %+v", frame.Packets[1])
+		}
+	})
+
+	tests := []struct {
+		name    string
+		updates map[uint16]models.Update
+		want    map[string]models.UpdateValue
+	}{
+		{"only changed values", update(map[string]models.UpdateValue{
+			"a": models.NumericValue{Value: 2},
+			"b": models.BooleanValue(false),
+		}), map[string]models.UpdateValue{"a": models.NumericValue{Value: 2}}},
+		{"nothing beyond the deadband", update(map[string]models.UpdateValue{
+			"a": models.NumericValue{Value: 1.2},
+		}), nil},
+		{"no updates", nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoder := newEncoder(time.Minute)
+
+			frame, ok := encoder.frame(test.updates, allUpdates)
+			if test.want == nil {
+				if ok {
+					t.Fatalf("expected no frame, got %+v", frame)
+				}
+				return
+			}
+
+			if !ok || frame.Keyframe || frame.Seq != 2 {
+				t.Fatalf("expected delta frame 2, got %+v", frame)
+			}
+			if frame.Packets[1].HexValue != "" || len(frame.Packets[1].Values) != len(test.want) {
+				t.Fatalf("expected %v, got %+v", test.want, frame.Packets[1])
+			}
+			for measurement, value := range test.want {
+				if frame.Packets[1].Values[measurement] != value {
+					t.Fatalf("expected %s to be %v, got %v", measurement, value, frame.Packets[1].Values[measurement])
+				}
+			}
+		})
+	}
+
+	t.Run("keyframe interval elapses without updates", func(t *testing.T) {
+		encoder := newEncoder(3 * time.Second)
+
+		for i := 0; i < 2; i++ {
+			if frame, ok := encoder.frame(nil, allUpdates); ok {
+				t.Fatalf("expected no frame, got %+v", frame)
+			}
+		}
+
+		frame, ok := encoder.frame(nil, allUpdates)
+		if !ok || !frame.Keyframe || frame.Seq != 2 {
+			t.Fatalf("expected keyframe 2, got %+v", frame)
+		}
+	})
+
+	t.Run("resync sends a keyframe", func(t *testing.T) {
+		encoder := newEncoder(time.Minute)
+		encoder.resync.Store(true)
+
+		frame, ok := encoder.frame(nil, allUpdates)
+		if !ok || !frame.Keyframe {
+			t.Fatalf("expected keyframe, got %+v", frame)
+		}
+	})
+}
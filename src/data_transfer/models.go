@@ -1,3 +1,17 @@
 package data_transfer
 
-type UpdateSubscription = bool
+import "github.com/HyperloopUPV-H8/Backend-H8/common/observable"
+
+// UpdateSubscription extends the generic subscription message with an optional filter and rate,
+// without them the client receives every packet at the global fps
+type UpdateSubscription struct {
+	observable.SubscriptionMessage
+	Packets      []uint16 `json:"packets,omitempty"`
+	Measurements []string `json:"measurements,omitempty"`
+	// Fps is rounded to a divisor of the global fps, zero or higher values use the global fps
+	Fps uint `json:"fps,omitempty"`
+	// Delta switches the subscription to DeltaFrame messages
+	Delta bool `json:"delta,omitempty"`
+	// Resync asks for a keyframe on the existing delta subscription with the same id
+	Resync bool `json:"resync,omitempty"`
+}
@@ -0,0 +1,164 @@
+package data_transfer
+
+import (
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/HyperloopUPV-H8/Backend-H8/update_factory/models"
+	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
+)
+
+// filteredObserver collects the updates the client asked for and writes them at the client rate.
+// Next is called by the update observable on each global tick, so it needs no lock
+type filteredObserver struct {
+	id           string
+	packets      common.Set[uint16]
+	measurements common.Set[string]
+	filtered     bool
+	// every is the number of global ticks between writes, ticks counts the ticks since the last one
+	every  uint
+	ticks  uint
+	buffer map[uint16]models.Update
+	// delta is nil unless the client asked for the delta protocol
+	delta  *deltaEncoder
+	write  func(any) error
+	onFail func()
+}
+
+func newFilteredObserver(subscription UpdateSubscription, globalFps uint, write func(any) error, onFail func()) *filteredObserver {
+	observer := &filteredObserver{
+		id:           subscription.Id,
+		packets:      common.NewSet[uint16](),
+		measurements: common.NewSet[string](),
+		filtered:     len(subscription.Packets) > 0 || len(subscription.Measurements) > 0,
+		every:        1,
+		buffer:       make(map[uint16]models.Update),
+		write:        write,
+		onFail:       onFail,
+	}
+
+	for _, packet := range subscription.Packets {
+		observer.packets.Add(packet)
+	}
+	for _, measurement := range subscription.Measurements {
+		observer.measurements.Add(measurement)
+	}
+
+	if subscription.Fps > 0 && subscription.Fps < globalFps {
+		observer.every = globalFps / subscription.Fps
+	}
+	// the replayed value is written right away
+	observer.ticks = observer.every - 1
+
+	return observer
+}
+
+func (observer *filteredObserver) Id() string {
+	return observer.id
+}
+
+func (observer *filteredObserver) Next(updates map[uint16]models.Update) {
+	for id, update := range updates {
+		if filtered, ok := observer.filter(update); ok {
+			observer.buffer[id] = filtered
+		}
+	}
+
+	observer.ticks++
+	if observer.ticks < observer.every {
+		return
+	}
+
+	if observer.delta != nil {
+		if frame, ok := observer.delta.frame(observer.buffer, observer.filter); ok {
+			observer.send(frame)
+		}
+	} else if len(observer.buffer) > 0 {
+		observer.send(observer.buffer)
+	} else {
+		return
+	}
+
+	observer.ticks = 0
+	observer.buffer = make(map[uint16]models.Update, len(observer.buffer))
+}
+
+func (observer *filteredObserver) send(payload any) {
+	// onFail unsubscribes, which has to wait for the broadcast calling Next to release the observable
+	if err := observer.write(payload); err != nil {
+		go observer.onFail()
+	}
+}
+
+// filter keeps the whole packet when it is subscribed, or only its subscribed measurements otherwise
+func (observer *filteredObserver) filter(update models.Update) (models.Update, bool) {
+	if !observer.filtered || observer.packets.Has(update.Id) {
+		return update, true
+	}
+
+	values := make(map[string]models.UpdateValue)
+	for id, value := range update.Values {
+		if observer.measurements.Has(id) {
+			values[id] = value
+		}
+	}
+
+	if len(values) == 0 {
+		return models.Update{}, false
+	}
+
+	update.Values = values
+	return update, true
+}
+
+func (dataTransfer *DataTransfer) handleSubscription(subscription UpdateSubscription, client wsModels.Client, topic string) {
+	if subscription.Resync {
+		dataTransfer.resync(subscription.Id)
+		return
+	}
+
+	if !subscription.Subscribe {
+		dataTransfer.unsubscribe(subscription.Id)
+		return
+	}
+
+	observer := newFilteredObserver(subscription, dataTransfer.fps, func(payload any) error {
+		return client.WriteMessage(topic, payload)
+	}, func() {
+		dataTransfer.unsubscribe(subscription.Id)
+	})
+
+	if subscription.Delta {
+		frameInterval := time.Second / time.Duration(dataTransfer.fps) * time.Duration(observer.every)
+		observer.delta = newDeltaEncoder(dataTransfer.delta, dataTransfer.keyframeInterval, frameInterval, dataTransfer.snapshot)
+
+		dataTransfer.deltaMx.Lock()
+		dataTransfer.deltaObservers[subscription.Id] = observer
+		dataTransfer.deltaMx.Unlock()
+	}
+
+	dataTransfer.trace.Debug().Str("client", client.Id()).Str("subscription", subscription.Id).Bool("filtered", observer.filtered).Uint("every", observer.every).Msg("subscribe")
+	dataTransfer.updateObservable.Subscribe(observer)
+}
+
+func (dataTransfer *DataTransfer) unsubscribe(id string) {
+	dataTransfer.updateObservable.Unsubscribe(id)
+
+	dataTransfer.deltaMx.Lock()
+	delete(dataTransfer.deltaObservers, id)
+	dataTransfer.deltaMx.Unlock()
+}
+
+// resync makes the next frame of the delta subscription a keyframe
+func (dataTransfer *DataTransfer) resync(id string) {
+	dataTransfer.deltaMx.Lock()
+	defer dataTransfer.deltaMx.Unlock()
+
+	observer, ok := dataTransfer.deltaObservers[id]
+	if !ok {
+		dataTransfer.trace.Warn().Str("subscription", id).Msg("resync of unknown delta subscription")
+		return
+	}
+
+	observer.delta.resync.Store(true)
+}
@@ -0,0 +1,80 @@
+package emergency_transfer
+
+import (
+	"encoding/json"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common/observable"
+	vehicle_models "github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
+	"github.com/rs/zerolog"
+	trace "github.com/rs/zerolog/log"
+)
+
+const (
+	EmergencyTransferHandlerName = "emergencyTransfer"
+)
+
+type EmergencyTransfer struct {
+	stop                func(source string, reason string) vehicle_models.EmergencyStop
+	emergencyObservable observable.ReplayObservable[*vehicle_models.EmergencyStop]
+	config              Config
+	trace               zerolog.Logger
+}
+
+type Config struct {
+	StopTopic   string `toml:"stop_topic"`
+	UpdateTopic string `toml:"update_topic"`
+}
+
+type stopRequest struct {
+	Reason string `json:"reason"`
+}
+
+func New(config Config) EmergencyTransfer {
+	trace.Info().Msg("new emergency transfer")
+	return EmergencyTransfer{
+		stop:                func(string, string) vehicle_models.EmergencyStop { return vehicle_models.EmergencyStop{} },
+		emergencyObservable: observable.NewReplayObservable[*vehicle_models.EmergencyStop](nil),
+		config:              config,
+		trace:               trace.With().Str("component", EmergencyTransferHandlerName).Logger(),
+	}
+}
+
+func (emergencyTransfer *EmergencyTransfer) SetEmergencyStop(stop func(source string, reason string) vehicle_models.EmergencyStop) {
+	emergencyTransfer.stop = stop
+}
+
+func (emergencyTransfer *EmergencyTransfer) UpdateMessage(client wsModels.Client, msg wsModels.Message) {
+	emergencyTransfer.trace.Info().Str("client", client.Id()).Str("topic", msg.Topic).Msg("got message")
+	switch msg.Topic {
+	case emergencyTransfer.config.StopTopic:
+		if client.IsReadOnly() {
+			emergencyTransfer.trace.Warn().Str("client", client.Id()).Msg("read only client tried to stop the vehicle")
+			return
+		}
+
+		var request stopRequest
+		if err := json.Unmarshal(msg.Payload, &request); err != nil {
+			// an emergency stop must never be dropped because of a malformed payload
+			emergencyTransfer.trace.Warn().Err(err).Msg("unmarshal stop request")
+		}
+
+		if request.Reason == "" {
+			request.Reason = "manual emergency stop"
+		}
+
+		emergencyTransfer.stop(client.Id(), request.Reason)
+	case emergencyTransfer.config.UpdateTopic:
+		observable.HandleSubscribe[*vehicle_models.EmergencyStop](&emergencyTransfer.emergencyObservable, msg, client)
+	}
+}
+
+// Record notifies subscribers of an emergency stop, whatever triggered it
+func (emergencyTransfer *EmergencyTransfer) Record(record vehicle_models.EmergencyStop) {
+	emergencyTransfer.trace.Warn().Str("source", record.Source).Str("reason", record.Reason).Any("results", record.Results).Msg("emergency stop")
+	emergencyTransfer.emergencyObservable.Next(&record)
+}
+
+func (emergencyTransfer *EmergencyTransfer) HandlerName() string {
+	return EmergencyTransferHandlerName
+}
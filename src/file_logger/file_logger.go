@@ -11,8 +11,10 @@ import (
 
 type FileLogger struct {
 	ids           common.Set[string]
+	header        []string
 	fileName      string
 	flushInterval time.Duration
+	csvOptions    logger_handler.CSVOptions
 	trace         zerolog.Logger
 }
 
@@ -21,7 +23,7 @@ type Config struct {
 	FlushInterval string `toml:"flush_interval"`
 }
 
-func NewFileLogger(name string, ids common.Set[string], config Config) FileLogger {
+func NewFileLogger(name string, ids common.Set[string], header []string, config Config) FileLogger {
 	trace := trace.With().Str("component", name).Logger()
 
 	flushInterval, err := time.ParseDuration(config.FlushInterval)
@@ -32,12 +34,17 @@ func NewFileLogger(name string, ids common.Set[string], config Config) FileLogge
 
 	return FileLogger{
 		ids:           ids,
+		header:        header,
 		fileName:      config.FileName,
 		flushInterval: flushInterval,
 		trace:         trace,
 	}
 }
 
+func (fl *FileLogger) SetCSVOptions(options logger_handler.CSVOptions) {
+	fl.csvOptions = options
+}
+
 func (fl *FileLogger) Ids() common.Set[string] {
 	return fl.ids
 }
@@ -51,7 +58,15 @@ func (fl *FileLogger) Start(basePath string) chan<- logger_handler.Loggable {
 }
 
 func (fl *FileLogger) startLoggingRoutine(loggableChan <-chan logger_handler.Loggable, basePath string) {
-	file := fl.createFile(basePath)
+	file, err := logger_handler.NewCSVFile(basePath, fl.fileName, fl.header, fl.csvOptions)
+	if err != nil {
+		// entries are discarded so the session keeps running for the other loggers
+		fl.trace.Error().Err(err).Msg("error creating file")
+		for range loggableChan {
+		}
+		return
+	}
+
 	flushTicker := time.NewTicker(fl.flushInterval)
 	done := make(chan struct{})
 	go fl.startFlushRoutine(flushTicker.C, file, done)
@@ -67,17 +82,7 @@ func (fl *FileLogger) startLoggingRoutine(loggableChan <-chan logger_handler.Log
 	file.Close()
 }
 
-func (fl *FileLogger) createFile(basePath string) logger_handler.CSVFile {
-	file, err := logger_handler.NewCSVFile(basePath, fl.fileName)
-
-	if err != nil {
-		fl.trace.Fatal().Err(err).Msg("error creating file")
-	}
-
-	return file
-}
-
-func (fl *FileLogger) startFlushRoutine(tickerChan <-chan time.Time, file logger_handler.CSVFile, done chan struct{}) {
+func (fl *FileLogger) startFlushRoutine(tickerChan <-chan time.Time, file *logger_handler.CSVFile, done chan struct{}) {
 loop:
 	for {
 		select {
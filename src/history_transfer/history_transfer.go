@@ -0,0 +1,267 @@
+package history_transfer
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/HyperloopUPV-H8/Backend-H8/packet"
+	vehicle_models "github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
+	"github.com/rs/zerolog"
+	trace "github.com/rs/zerolog/log"
+)
+
+const HistoryTransferHandlerName = "historyTransfer"
+
+const (
+	DEFAULT_RETENTION  = "5m"
+	DEFAULT_RESOLUTION = "250ms"
+	DEFAULT_POINTS     = 500
+)
+
+type Config struct {
+	Topic string `toml:"topic"`
+	// Retention is how far back the history goes, Resolution the width of the stored buckets
+	Retention  string `toml:"retention"`
+	Resolution string `toml:"resolution"`
+	// MaxPoints caps the buckets of each series in a response
+	MaxPoints int `toml:"max_points"`
+}
+
+// HistoryTransfer keeps the recent history of every numeric and boolean measurement
+// downsampled in fixed width buckets, and answers the history requests of the clients
+type HistoryTransfer struct {
+	seriesMx   *sync.Mutex
+	series     map[string]*series
+	size       int
+	resolution time.Duration
+	maxPoints  int
+	trace      zerolog.Logger
+}
+
+// HistoryRequest asks for the last Seconds of the measurements or, when From is set, the range [From, To].
+// Id is echoed back so the client can tell the responses apart
+type HistoryRequest struct {
+	Id           string    `json:"id"`
+	Measurements []string  `json:"measurements"`
+	Seconds      float64   `json:"seconds,omitempty"`
+	From         time.Time `json:"from,omitempty"`
+	To           time.Time `json:"to,omitempty"`
+	Points       int       `json:"points,omitempty"`
+}
+
+type HistoryResponse struct {
+	Id     string                     `json:"id"`
+	Series map[string][]common.Bucket `json:"series"`
+}
+
+func New(config Config) HistoryTransfer {
+	trace.Info().Msg("create history transfer")
+	historyTrace := trace.With().Str("component", HistoryTransferHandlerName).Logger()
+
+	retention := common.ParseDuration(config.Retention, DEFAULT_RETENTION, historyTrace)
+	resolution := common.ParseDuration(config.Resolution, DEFAULT_RESOLUTION, historyTrace)
+	if retention <= 0 || resolution <= 0 {
+		historyTrace.Fatal().Dur("retention", retention).Dur("resolution", resolution).Msg("history durations must be positive")
+	}
+
+	size := int(retention / resolution)
+	if size < 1 {
+		size = 1
+	}
+
+	maxPoints := config.MaxPoints
+	if maxPoints <= 0 {
+		maxPoints = DEFAULT_POINTS
+	}
+
+	return HistoryTransfer{
+		seriesMx:   &sync.Mutex{},
+		series:     make(map[string]*series),
+		size:       size,
+		resolution: resolution,
+		maxPoints:  maxPoints,
+		trace:      historyTrace,
+	}
+}
+
+func (history *HistoryTransfer) HandlerName() string {
+	return HistoryTransferHandlerName
+}
+
+func (history *HistoryTransfer) UpdateMessage(client wsModels.Client, msg wsModels.Message) {
+	history.trace.Debug().Str("client", client.Id()).Str("topic", msg.Topic).Msg("got message")
+
+	var request HistoryRequest
+	if err := json.Unmarshal(msg.Payload, &request); err != nil {
+		history.trace.Error().Err(err).Msg("unmarshal history request")
+		return
+	}
+
+	if err := client.WriteMessage(msg.Topic, history.Query(request)); err != nil {
+		history.trace.Error().Err(err).Str("client", client.Id()).Msg("sending history response")
+	}
+}
+
+// Update adds the numeric and boolean values of the packet to their history, enums are not kept
+func (history *HistoryTransfer) Update(update vehicle_models.PacketUpdate) {
+	history.seriesMx.Lock()
+	defer history.seriesMx.Unlock()
+
+	for id, value := range update.Values {
+		number, ok := toFloat(value)
+		if !ok {
+			continue
+		}
+
+		measurementSeries, ok := history.series[id]
+		if !ok {
+			measurementSeries = newSeries(history.size)
+			history.series[id] = measurementSeries
+		}
+
+		measurementSeries.add(update.Metadata.Timestamp.Truncate(history.resolution), number)
+	}
+}
+
+func toFloat(value packet.Value) (float64, bool) {
+	switch value := value.(type) {
+	case packet.Numeric:
+		return float64(value), true
+	case packet.Boolean:
+		if value {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// Query merges the stored buckets inside the requested range into at most Points buckets per measurement
+func (history *HistoryTransfer) Query(request HistoryRequest) HistoryResponse {
+	to := request.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	from := request.From
+	if from.IsZero() {
+		if request.Seconds > 0 {
+			from = to.Add(-time.Duration(request.Seconds * float64(time.Second)))
+		} else {
+			from = to.Add(-time.Duration(history.size) * history.resolution)
+		}
+	}
+
+	points := request.Points
+	if points <= 0 || points > history.maxPoints {
+		points = history.maxPoints
+	}
+
+	width := to.Sub(from) / time.Duration(points)
+	if width < history.resolution {
+		width = history.resolution
+	}
+
+	response := HistoryResponse{Id: request.Id, Series: make(map[string][]common.Bucket, len(request.Measurements))}
+
+	history.seriesMx.Lock()
+	defer history.seriesMx.Unlock()
+
+	for _, id := range request.Measurements {
+		measurementSeries, ok := history.series[id]
+		if !ok {
+			response.Series[id] = []common.Bucket{}
+			continue
+		}
+
+		response.Series[id] = measurementSeries.query(from, to, width)
+	}
+
+	return response
+}
+
+type bucket struct {
+	start time.Time
+	min   float64
+	max   float64
+	sum   float64
+	count int64
+}
+
+func (b *bucket) add(value float64) {
+	if b.count == 0 || value < b.min {
+		b.min = value
+	}
+	if b.count == 0 || value > b.max {
+		b.max = value
+	}
+	b.sum += value
+	b.count++
+}
+
+func (b *bucket) merge(other bucket) {
+	if b.count == 0 || other.min < b.min {
+		b.min = other.min
+	}
+	if b.count == 0 || other.max > b.max {
+		b.max = other.max
+	}
+	b.sum += other.sum
+	b.count += other.count
+}
+
+// series keeps the closed buckets in a ring, the one being filled is kept apart until its time is over
+type series struct {
+	buckets common.RingBuf[bucket]
+	current bucket
+}
+
+func newSeries(size int) *series {
+	return &series{
+		buckets: common.NewRingBuf[bucket](size),
+	}
+}
+
+func (s *series) add(start time.Time, value float64) {
+	if s.current.count > 0 && start.After(s.current.start) {
+		s.buckets.Add(s.current)
+		s.current = bucket{}
+	}
+
+	if s.current.count == 0 {
+		s.current.start = start
+	}
+	s.current.add(value)
+}
+
+func (s *series) query(from time.Time, to time.Time, width time.Duration) []common.Bucket {
+	merged := make([]bucket, 0)
+	for _, stored := range append(s.buckets.Items(), s.current) {
+		if stored.count == 0 || stored.start.Before(from) || stored.start.After(to) {
+			continue
+		}
+
+		start := from.Add(stored.start.Sub(from) / width * width)
+		if len(merged) == 0 || !merged[len(merged)-1].start.Equal(start) {
+			merged = append(merged, bucket{start: start})
+		}
+		merged[len(merged)-1].merge(stored)
+	}
+
+	buckets := make([]common.Bucket, len(merged))
+	for i, b := range merged {
+		buckets[i] = common.Bucket{
+			Timestamp: b.start,
+			Min:       b.min,
+			Max:       b.max,
+			Mean:      b.sum / float64(b.count),
+			Count:     b.count,
+		}
+	}
+
+	return buckets
+}
@@ -0,0 +1,74 @@
+package history_transfer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+)
+
+func TestSeriesQuery(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	type sample struct {
+		second int
+		value  float64
+	}
+
+	samples := []sample{{0, 1}, {0, 3}, {1, 5}, {2, 2}, {3, 4}, {3, 8}}
+
+	tests := []struct {
+		name  string
+		size  int
+		from  time.Time
+		to    time.Time
+		width time.Duration
+		want  []common.Bucket
+	}{
+		{"one bucket per resolution", 10, at(0), at(3), time.Second, []common.Bucket{
+			{Timestamp: at(0), Min: 1, Max: 3, Mean: 2, Count: 2},
+			{Timestamp: at(1), Min: 5, Max: 5, Mean: 5, Count: 1},
+			{Timestamp: at(2), Min: 2, Max: 2, Mean: 2, Count: 1},
+			{Timestamp: at(3), Min: 4, Max: 8, Mean: 6, Count: 2},
+		}},
+		{"merged buckets", 10, at(0), at(3), 2 * time.Second, []common.Bucket{
+			{Timestamp: at(0), Min: 1, Max: 5, Mean: 3, Count: 3},
+			{Timestamp: at(2), Min: 2, Max: 8, Mean: 14.0 / 3, Count: 3},
+		}},
+		{"merged from the range start", 10, at(1), at(3), 2 * time.Second, []common.Bucket{
+			{Timestamp: at(1), Min: 2, Max: 5, Mean: 3.5, Count: 2},
+			{Timestamp: at(3), Min: 4, Max: 8, Mean: 6, Count: 2},
+		}},
+		{"range excludes buckets", 10, at(1), at(2), time.Second, []common.Bucket{
+			{Timestamp: at(1), Min: 5, Max: 5, Mean: 5, Count: 1},
+			{Timestamp: at(2), Min: 2, Max: 2, Mean: 2, Count: 1},
+		}},
+		{"evicted buckets", 2, at(0), at(3), 4 * time.Second, []common.Bucket{
+			{Timestamp: at(0), Min: 2, Max: 8, Mean: 19.0 / 4, Count: 4},
+		}},
+		{"empty range", 10, at(4), at(5), time.Second, []common.Bucket{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			series := newSeries(test.size)
+			for _, sample := range samples {
+				series.add(at(sample.second), sample.value)
+			}
+
+			got := series.query(test.from, test.to, test.width)
+			if len(got) != len(test.want) {
+				t.Fatalf("expected %d buckets, got %+v", len(test.want), got)
+			}
+
+			for i := range got {
+				if !got[i].Timestamp.Equal(test.want[i].Timestamp) || got[i].Min != test.want[i].Min || got[i].Max != test.want[i].Max || got[i].Mean != test.want[i].Mean || got[i].Count != test.want[i].Count {
+					t.Fatalf("bucket %d: expected %+v, got %+v", i, test.want[i], got[i])
+				}
+			}
+		})
+	}
+}
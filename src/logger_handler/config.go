@@ -1,11 +1,74 @@
 package logger_handler
 
+import "github.com/HyperloopUPV-H8/Backend-H8/condition"
+
 type Config struct {
 	Topics        LoggerTopics `toml:"topics"`
 	BasePath      string       `toml:"base_path"`
 	FlushInterval string       `toml:"flush_interval"`
+	// AutoStart starts a session as soon as the backend launches
+	AutoStart bool `toml:"auto_start,omitempty"`
+	// Endpoint serves the recorded sessions over HTTP
+	Endpoint string `toml:"endpoint,omitempty"`
+	// QueueSize is the capacity of the general queue and of each logger queue, entries are dropped when they are full
+	QueueSize     int    `toml:"queue_size,omitempty"`
+	StatsInterval string `toml:"stats_interval,omitempty"`
+
+	// Compression of the CSV files, either "gzip", "zstd" or empty to write them raw
+	Compression string `toml:"compression,omitempty"`
+
+	Rotation  RotationConfig  `toml:"rotation"`
+	Retention RetentionConfig `toml:"retention"`
+	Disk      DiskConfig      `toml:"disk"`
+	Trigger   TriggerConfig   `toml:"trigger"`
+}
+
+// RotationConfig limits the size and age of each CSV file, zero or empty values disable the limit
+type RotationConfig struct {
+	MaxSizeMB int64  `toml:"max_size_mb,omitempty"`
+	MaxAge    string `toml:"max_age,omitempty"`
+}
+
+// RetentionConfig deletes old sessions when a new one starts, zero or empty values keep everything
+type RetentionConfig struct {
+	MaxSessions int    `toml:"max_sessions,omitempty"`
+	MaxAge      string `toml:"max_age,omitempty"`
+}
+
+// DiskConfig warns when the free space under BasePath drops below WarnFreeMB and stops logging below MinFreeMB
+type DiskConfig struct {
+	WarnFreeMB    uint64 `toml:"warn_free_mb,omitempty"`
+	MinFreeMB     uint64 `toml:"min_free_mb,omitempty"`
+	CheckInterval string `toml:"check_interval,omitempty"`
 }
 
+const (
+	DEFAULT_QUEUE_SIZE     = 4096
+	DEFAULT_STATS_INTERVAL = "5s"
+	DEFAULT_DISK_INTERVAL  = "10s"
+)
+
 type LoggerTopics struct {
-	Enable string `toml:"enable"`
+	Enable   string `toml:"enable"`
+	Start    string `toml:"start"`
+	Stop     string `toml:"stop"`
+	Session  string `toml:"session"`
+	Sessions string `toml:"sessions"`
+	Stats    string `toml:"stats"`
+	Trigger  string `toml:"trigger"`
+}
+
+// TriggerConfig keeps the last BufferSize loggables received with logging stopped and, when a trigger fires,
+// logs the ones from the last PreTrigger in a new session that stops after PostTrigger
+type TriggerConfig struct {
+	Enable      bool   `toml:"enable"`
+	BufferSize  int    `toml:"buffer_size,omitempty"`
+	PreTrigger  string `toml:"pre_trigger,omitempty"`
+	PostTrigger string `toml:"post_trigger,omitempty"`
+	// OnFault triggers when any board reports a fault
+	OnFault bool `toml:"on_fault"`
+	// Orders lists the ids of the orders that trigger when sent
+	Orders []uint16 `toml:"orders"`
+	// Thresholds trigger when their condition starts being met
+	Thresholds []condition.Condition `toml:"thresholds"`
 }
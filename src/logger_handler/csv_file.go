@@ -1,48 +1,188 @@
 package logger_handler
 
 import (
+	"compress/gzip"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/klauspost/compress/zstd"
 	trace "github.com/rs/zerolog/log"
 )
 
+const (
+	NoCompression   = ""
+	GzipCompression = "gzip"
+	ZstdCompression = "zstd"
+)
+
+// compressor is a streaming encoder, Flush must leave everything written so far decodable
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressionExtension returns the extension appended to the files compressed with kind
+func compressionExtension(kind string) (string, error) {
+	switch kind {
+	case NoCompression:
+		return "", nil
+	case GzipCompression:
+		return ".gz", nil
+	case ZstdCompression:
+		return ".zst", nil
+	default:
+		return "", fmt.Errorf("unknown compression %q", kind)
+	}
+}
+
+func newCompressor(kind string, output io.Writer) (compressor, error) {
+	switch kind {
+	case GzipCompression:
+		return gzip.NewWriter(output), nil
+	case ZstdCompression:
+		return zstd.NewWriter(output, zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, nil
+	}
+}
+
+// Rotation closes a file and continues in a new part once it grows past MaxSize bytes or is older than MaxAge,
+// zero values disable each limit
+type Rotation struct {
+	MaxSize int64
+	MaxAge  time.Duration
+}
+
+// CSVOptions are the rotation and compression applied to a CSVFile
+type CSVOptions struct {
+	Rotation    Rotation
+	Compression string
+}
+
 type CSVFile struct {
 	fileMx *sync.Mutex
 	file   *os.File
 	writer *csv.Writer
+	size   *countingWriter
+	// compressor is nil when the file is written raw
+	compressor compressor
+
+	path        string
+	name        string
+	header      []string
+	rotation    Rotation
+	compression string
+	part        int
+	openedAt    time.Time
 }
 
-func NewCSVFile(path, name string) (CSVFile, error) {
+// NewCSVFile creates the file and writes the header as its first row, a nil header writes no row.
+// Rotated parts are named name_001.csv, name_002.csv... and start with the same header.
+// Compressed files get a .gz or .zst extension appended
+func NewCSVFile(path, name string, header []string, options CSVOptions) (*CSVFile, error) {
 	trace.Debug().Str("path", path).Str("name", name).Msg("creating save file")
 	if err := os.MkdirAll(path, 0777); err != nil {
 		trace.Error().Stack().Err(err).Str("path", path).Str("name", name).Msg("failed to create directory")
-		return CSVFile{}, err
+		return nil, err
+	}
+
+	csvFile := &CSVFile{
+		fileMx:      &sync.Mutex{},
+		path:        path,
+		name:        name,
+		header:      header,
+		rotation:    options.Rotation,
+		compression: options.Compression,
 	}
 
-	fileName := fmt.Sprintf("%s.csv", name)
-	file, err := os.Create(filepath.Join(path, fileName))
-	os.Chmod(filepath.Join(path, fileName), 0777)
+	if err := csvFile.open(); err != nil {
+		return nil, err
+	}
 
+	return csvFile, nil
+}
+
+func (file *CSVFile) fileName(extension string) string {
+	if file.part == 0 {
+		return fmt.Sprintf("%s.csv%s", file.name, extension)
+	}
+	return fmt.Sprintf("%s_%03d.csv%s", file.name, file.part, extension)
+}
+
+func (file *CSVFile) open() error {
+	extension, err := compressionExtension(file.compression)
 	if err != nil {
-		trace.Error().Stack().Err(err).Str("path", path).Str("name", name).Msg("failed to create file")
-		return CSVFile{}, err
+		return err
 	}
 
-	return CSVFile{
-		fileMx: &sync.Mutex{},
-		file:   file,
-		writer: csv.NewWriter(file),
-	}, nil
+	fileName := filepath.Join(file.path, file.fileName(extension))
+	osFile, err := os.Create(fileName)
+	if err != nil {
+		trace.Error().Stack().Err(err).Str("path", file.path).Str("name", file.name).Msg("failed to create file")
+		return err
+	}
+	os.Chmod(fileName, 0777)
+
+	file.file = osFile
+	file.size = &countingWriter{writer: osFile}
+	file.compressor, err = newCompressor(file.compression, file.size)
+	if err != nil {
+		osFile.Close()
+		return err
+	}
+
+	if file.compressor != nil {
+		file.writer = csv.NewWriter(file.compressor)
+	} else {
+		file.writer = csv.NewWriter(file.size)
+	}
+	file.openedAt = time.Now()
+
+	if file.header != nil {
+		if err := file.writer.Write(file.header); err != nil {
+			osFile.Close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (file *CSVFile) shouldRotate() bool {
+	if file.rotation.MaxSize > 0 && file.size.count >= file.rotation.MaxSize {
+		return true
+	}
+
+	return file.rotation.MaxAge > 0 && time.Since(file.openedAt) >= file.rotation.MaxAge
+}
+
+// rotateUnsafe must be called with fileMx held
+func (file *CSVFile) rotateUnsafe() error {
+	if err := file.closeUnsafe(); err != nil {
+		return err
+	}
+
+	file.part++
+	trace.Debug().Str("path", file.path).Str("name", file.name).Int("part", file.part).Msg("rotating save file")
+	return file.open()
 }
 
 func (file *CSVFile) Write(data []string) error {
 	file.fileMx.Lock()
 	defer file.fileMx.Unlock()
 
+	if file.shouldRotate() {
+		if err := file.rotateUnsafe(); err != nil {
+			trace.Error().Stack().Err(err).Str("name", file.name).Msg("failed to rotate csv")
+			return err
+		}
+	}
+
 	trace.Trace().Strs("data", data).Msg("writing csv")
 	if err := file.writer.Write(data); err != nil {
 		trace.Error().Stack().Err(err).Strs("data", data).Msg("failed to write csv")
@@ -58,10 +198,22 @@ func (file *CSVFile) Flush() error {
 	return file.flushUnsafe()
 }
 
+// flushUnsafe pushes the buffered rows through the compressor to the operating system,
+// so the file stays readable up to this point if the backend crashes. Only closing syncs the file to the disk
 func (file *CSVFile) flushUnsafe() error {
 	trace.Debug().Msg("flushing save file")
 	file.writer.Flush()
-	return file.writer.Error()
+	if err := file.writer.Error(); err != nil {
+		return err
+	}
+
+	if file.compressor != nil {
+		if err := file.compressor.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func FlushFiles[T comparable](files map[T]*CSVFile) (err error) {
@@ -78,10 +230,30 @@ func (file *CSVFile) Close() error {
 	file.fileMx.Lock()
 	defer file.fileMx.Unlock()
 
+	return file.closeUnsafe()
+}
+
+func (file *CSVFile) closeUnsafe() error {
 	trace.Debug().Msg("closing save file")
 
 	if err := file.flushUnsafe(); err != nil {
 		trace.Error().Stack().Err(err).Msg("failed to flush writer")
+		file.file.Close()
+		return err
+	}
+
+	if file.compressor != nil {
+		if err := file.compressor.Close(); err != nil {
+			trace.Error().Stack().Err(err).Msg("failed to close compressor")
+			file.file.Close()
+			return err
+		}
+	}
+
+	// closed parts, including the ones left by a rotation, are synced once instead of on every flush
+	if err := file.file.Sync(); err != nil {
+		trace.Error().Stack().Err(err).Msg("failed to sync file")
+		file.file.Close()
 		return err
 	}
 
@@ -97,3 +269,14 @@ func CloseFiles[T comparable](files map[T]*CSVFile) (err error) {
 	}
 	return err
 }
+
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (counter *countingWriter) Write(p []byte) (int, error) {
+	n, err := counter.writer.Write(p)
+	counter.count += int64(n)
+	return n, err
+}
@@ -0,0 +1,96 @@
+package logger_handler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const megabyte = 1 << 20
+
+var ErrDiskFull = errors.New("not enough free disk space to log")
+
+// hasFreeSpace reports false only when the free space is known to be below the minimum
+func (handler *LoggerHandler) hasFreeSpace() bool {
+	if handler.config.Disk.MinFreeMB == 0 {
+		return true
+	}
+
+	free, err := freeSpace(handler.config.BasePath)
+	if err != nil {
+		handler.trace.Error().Err(err).Msg("checking free space")
+		return true
+	}
+
+	return free >= handler.config.Disk.MinFreeMB*megabyte
+}
+
+// startDiskRoutine stops the session before the disk fills up, warning once when the free space gets low
+func (handler *LoggerHandler) startDiskRoutine(done <-chan struct{}) {
+	if handler.config.Disk.WarnFreeMB == 0 && handler.config.Disk.MinFreeMB == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(handler.diskInterval)
+	defer ticker.Stop()
+
+	warned := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		free, err := freeSpace(handler.config.BasePath)
+		if err != nil {
+			handler.trace.Error().Err(err).Msg("checking free space")
+			continue
+		}
+
+		if free < handler.config.Disk.MinFreeMB*megabyte {
+			handler.warn(fmt.Sprintf("logging stopped, only %d MB free on disk", free/megabyte))
+			if _, err := handler.StopSession(); err != nil {
+				handler.trace.Error().Err(err).Msg("stopping session on low disk space")
+			}
+			return
+		}
+
+		if free < handler.config.Disk.WarnFreeMB*megabyte && !warned {
+			handler.warn(fmt.Sprintf("low disk space, %d MB free", free/megabyte))
+			warned = true
+		} else if free >= handler.config.Disk.WarnFreeMB*megabyte {
+			warned = false
+		}
+	}
+}
+
+// applyRetention removes the sessions exceeding the retention policy, leaving room for the session about to start.
+// It must be called with no session running
+func (handler *LoggerHandler) applyRetention() {
+	if handler.config.Retention.MaxSessions <= 0 && handler.retentionAge <= 0 {
+		return
+	}
+
+	sessions, err := ListSessions(handler.config.BasePath)
+	if err != nil {
+		handler.trace.Error().Err(err).Msg("listing sessions for retention")
+		return
+	}
+
+	now := time.Now()
+	for i, session := range sessions {
+		tooMany := handler.config.Retention.MaxSessions > 0 && i >= handler.config.Retention.MaxSessions-1
+		tooOld := handler.retentionAge > 0 && now.Sub(session.StartTime) > handler.retentionAge
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		handler.trace.Info().Str("session", session.Name).Bool("tooMany", tooMany).Bool("tooOld", tooOld).Msg("removing session by retention policy")
+		if err := os.RemoveAll(filepath.Join(handler.config.BasePath, session.Name)); err != nil {
+			handler.trace.Error().Err(err).Str("session", session.Name).Msg("removing session")
+		}
+	}
+}
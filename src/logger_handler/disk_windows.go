@@ -0,0 +1,20 @@
+//go:build windows
+
+package logger_handler
+
+import "golang.org/x/sys/windows"
+
+// freeSpace returns the bytes available to the backend on the volume holding path
+func freeSpace(path string) (uint64, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(pathPtr, &available, &total, &free); err != nil {
+		return 0, err
+	}
+
+	return available, nil
+}
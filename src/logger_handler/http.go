@@ -0,0 +1,335 @@
+package logger_handler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/gorilla/mux"
+)
+
+type SessionFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+type SessionDetails struct {
+	Session
+	Files []SessionFile `json:"files"`
+}
+
+// Handlers returns the session browser endpoints keyed by their path relative to endpoint
+func (handler *LoggerHandler) Handlers(endpoint string) map[string]http.Handler {
+	return map[string]http.Handler{
+		endpoint:                                http.HandlerFunc(handler.handleSessionList),
+		endpoint + "/{session}":                 http.HandlerFunc(handler.handleSession),
+		endpoint + "/{session}/archive":         http.HandlerFunc(handler.handleArchive),
+		endpoint + "/{session}/files/{file:.+}": http.HandlerFunc(handler.handleFile),
+	}
+}
+
+// handleSessionList lists the sessions or, on DELETE, removes the ones started before the "before" query parameter
+func (handler *LoggerHandler) handleSessionList(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sessions, err := handler.Sessions()
+		if err != nil {
+			handler.trace.Error().Err(err).Msg("listing sessions")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		common.WriteJSON(w, http.StatusOK, sessions)
+	case http.MethodDelete:
+		before, err := time.Parse(time.RFC3339, r.URL.Query().Get("before"))
+		if err != nil {
+			http.Error(w, "before must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+
+		deleted, err := handler.deleteSessionsBefore(before)
+		if err != nil {
+			handler.trace.Error().Err(err).Msg("deleting sessions")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		common.WriteJSON(w, http.StatusOK, deleted)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (handler *LoggerHandler) handleSession(w http.ResponseWriter, r *http.Request) {
+	path, err := handler.sessionPath(mux.Vars(r)["session"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		details, err := handler.sessionDetails(path)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		common.WriteJSON(w, http.StatusOK, details)
+	case http.MethodDelete:
+		if err := handler.deleteSession(path); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (handler *LoggerHandler) handleFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path, err := handler.sessionPath(mux.Vars(r)["session"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	file := filepath.Join(path, filepath.FromSlash(mux.Vars(r)["file"]))
+	if rel, err := filepath.Rel(path, file); err != nil || strings.HasPrefix(rel, "..") {
+		http.Error(w, "invalid file", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(file)))
+	http.ServeFile(w, r, file)
+}
+
+// handleArchive streams the whole session as a zip (default) or tar.gz, selected with the "format" query parameter
+func (handler *LoggerHandler) handleArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := mux.Vars(r)["session"]
+	path, err := handler.sessionPath(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var write func(io.Writer, string) error
+	var extension string
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "zip":
+		write, extension = writeZip, "zip"
+	case "tar.gz", "tgz":
+		write, extension = writeTarGz, "tar.gz"
+	default:
+		http.Error(w, fmt.Sprintf("unknown format %s", format), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s.%s", name, extension)))
+	if err := write(w, path); err != nil {
+		// headers are already sent, the client gets a truncated archive
+		handler.trace.Error().Err(err).Str("session", name).Msg("writing archive")
+	}
+}
+
+var errInvalidSession = errors.New("invalid session name")
+var errSessionRunning = errors.New("session is running")
+
+func (handler *LoggerHandler) sessionPath(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", errInvalidSession
+	}
+
+	path := filepath.Join(handler.config.BasePath, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !info.IsDir() {
+		return "", fs.ErrNotExist
+	}
+
+	return path, nil
+}
+
+func (handler *LoggerHandler) sessionDetails(path string) (SessionDetails, error) {
+	session, err := readSession(path)
+	if err != nil {
+		return SessionDetails{}, err
+	}
+	session.Running = handler.isSessionRunning(session.Name)
+	session.updateDuration(time.Now())
+
+	files := make([]SessionFile, 0)
+	err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(path, file)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, SessionFile{Path: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+
+	return SessionDetails{Session: session, Files: files}, err
+}
+
+func (handler *LoggerHandler) isSessionRunning(name string) bool {
+	handler.isRunningMx.Lock()
+	defer handler.isRunningMx.Unlock()
+	return handler.isRunning && handler.session.Name == name
+}
+
+func (handler *LoggerHandler) deleteSession(path string) error {
+	if handler.isSessionRunning(filepath.Base(path)) {
+		return errSessionRunning
+	}
+
+	handler.trace.Info().Str("session", filepath.Base(path)).Msg("deleting session")
+	return os.RemoveAll(path)
+}
+
+func (handler *LoggerHandler) deleteSessionsBefore(before time.Time) ([]string, error) {
+	sessions, err := handler.Sessions()
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]string, 0)
+	for _, session := range sessions {
+		if session.Running || !session.StartTime.Before(before) {
+			continue
+		}
+
+		if err := handler.deleteSession(filepath.Join(handler.config.BasePath, session.Name)); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, session.Name)
+	}
+
+	return deleted, nil
+}
+
+func writeZip(output io.Writer, path string) error {
+	archive := zip.NewWriter(output)
+
+	err := walkFiles(path, func(rel string, info fs.FileInfo, file io.Reader) error {
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+		header.Method = zip.Deflate
+
+		writer, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(writer, file)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func writeTarGz(output io.Writer, path string) error {
+	compressor := gzip.NewWriter(output)
+	archive := tar.NewWriter(compressor)
+
+	err := walkFiles(path, func(rel string, info fs.FileInfo, file io.Reader) error {
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+
+		// the file may still be growing if the session is running, only the size in the header is copied
+		_, err = io.CopyN(archive, file, info.Size())
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	return compressor.Close()
+}
+
+// walkFiles calls write for every regular file under path with its slash separated relative path
+func walkFiles(path string, write func(rel string, info fs.FileInfo, file io.Reader) error) error {
+	return filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(path, name)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		return write(filepath.ToSlash(rel), info, file)
+	})
+}
+
+var errorStatuses = []common.ErrorStatus{
+	{Err: fs.ErrNotExist, Status: http.StatusNotFound, Message: "session not found"},
+	{Err: errInvalidSession, Status: http.StatusBadRequest},
+	{Err: errSessionRunning, Status: http.StatusConflict},
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	common.WriteError(w, err, errorStatuses)
+}
@@ -6,8 +6,11 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/HyperloopUPV-H8/Backend-H8/common/observable"
 	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
 
 	"github.com/rs/zerolog"
@@ -20,174 +23,440 @@ const (
 )
 
 type LoggerHandler struct {
-	loggers       map[string]Logger
-	loggableChan  chan Loggable
-	currentClient *wsModels.Client
-	isRunning     bool
-	isRunningMx   *sync.Mutex
-	config        Config
-	trace         zerolog.Logger
+	loggers      map[string]Logger
+	loggableChan chan Loggable
+	dropped      *atomic.Uint64
+	statsDone    chan struct{}
+	session      *Session
+	isRunning    bool
+	isRunningMx  *sync.Mutex
+	adeVersion   string
+
+	queueSize     int
+	statsInterval time.Duration
+	diskInterval  time.Duration
+	retentionAge  time.Duration
+	onWarning     func(msg string)
+	catalog       IdCatalog
+	// recorder is nil when trigger logging is disabled
+	recorder *recorder
+
+	sessionObservable observable.ReplayObservable[*Session]
+	statsObservable   observable.ReplayObservable[*PipelineStats]
+
+	config Config
+	trace  zerolog.Logger
 }
 
 func NewLoggerHandler(loggers map[string]Logger, config Config) LoggerHandler {
 	trace.Info().Msg("new LoggerHandler")
 
+	handlerTrace := trace.With().Str("component", LogHandlerHandlerName).Logger()
+
 	os.MkdirAll(config.BasePath, 0777)
 	os.Chmod(config.BasePath, 0777)
 
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = DEFAULT_QUEUE_SIZE
+	}
+
+	if _, err := compressionExtension(config.Compression); err != nil {
+		handlerTrace.Fatal().Err(err).Msg("error parsing compression")
+	}
+
+	csvOptions := CSVOptions{
+		Rotation: Rotation{
+			MaxSize: config.Rotation.MaxSizeMB * megabyte,
+			MaxAge:  common.ParseDuration(config.Rotation.MaxAge, "0s", handlerTrace),
+		},
+		Compression: config.Compression,
+	}
+
+	for _, logger := range loggers {
+		if csvLogger, ok := logger.(CSVLogger); ok {
+			csvLogger.SetCSVOptions(csvOptions)
+		}
+	}
+
 	return LoggerHandler{
-		loggers:       loggers,
-		loggableChan:  make(chan Loggable),
-		currentClient: nil,
-		isRunning:     false,
-		isRunningMx:   &sync.Mutex{},
+		loggers:      loggers,
+		loggableChan: make(chan Loggable, queueSize),
+		dropped:      &atomic.Uint64{},
+		session:      nil,
+		isRunning:    false,
+		isRunningMx:  &sync.Mutex{},
+
+		queueSize:     queueSize,
+		statsInterval: common.ParseDuration(config.StatsInterval, DEFAULT_STATS_INTERVAL, handlerTrace),
+		diskInterval:  common.ParseDuration(config.Disk.CheckInterval, DEFAULT_DISK_INTERVAL, handlerTrace),
+		retentionAge:  common.ParseDuration(config.Retention.MaxAge, "0s", handlerTrace),
+		onWarning:     func(string) {},
+		recorder: newRecorder(config.Trigger, func(value string, fallback string) time.Duration {
+			return common.ParseDuration(value, fallback, handlerTrace)
+		}),
+
+		sessionObservable: observable.NewReplayObservable[*Session](nil),
+		statsObservable:   observable.NewReplayObservable[*PipelineStats](nil),
 
 		config: config,
-		trace:  trace.With().Str("component", LogHandlerHandlerName).Logger(),
+		trace:  handlerTrace,
 	}
 }
 
+// SetCatalog lets session selections refer to boards and packets
+func (handler *LoggerHandler) SetCatalog(catalog IdCatalog) {
+	handler.catalog = catalog
+}
+
+// SetOnWarning sets the callback used to tell the operators that logging is falling behind
+func (handler *LoggerHandler) SetOnWarning(onWarning func(msg string)) {
+	handler.onWarning = onWarning
+}
+
+// SetAdeVersion sets the version of the ADE stored in the metadata of new sessions
+func (handler *LoggerHandler) SetAdeVersion(version string) {
+	handler.adeVersion = version
+}
+
+// Log never blocks, the entry is dropped if the logging queue is full
 func (handler *LoggerHandler) Log(loggable Loggable) {
 	handler.isRunningMx.Lock()
-	if handler.isRunning {
-		handler.loggableChan <- loggable
+	defer handler.isRunningMx.Unlock()
+
+	if !handler.isRunning {
+		if handler.recorder != nil {
+			handler.recorder.buffer.Add(bufferedLoggable{timestamp: time.Now(), loggable: loggable})
+		}
+		return
+	}
+
+	select {
+	case handler.loggableChan <- loggable:
+	default:
+		handler.dropped.Add(1)
 	}
-	handler.isRunningMx.Unlock()
 }
 
 func (handler *LoggerHandler) UpdateMessage(client wsModels.Client, msg wsModels.Message) {
 	handler.trace.Info().Str("topic", msg.Topic).Str("client", client.Id()).Msg("update message")
 	switch msg.Topic {
 	case handler.config.Topics.Enable:
-		var enable bool
-		err := json.Unmarshal(msg.Payload, &enable)
-		if err != nil {
-			handler.trace.Error().Stack().Err(err).Msg("unmarshal enable")
+		if client.IsReadOnly() {
+			handler.trace.Warn().Str("client", client.Id()).Msg("read only client tried to change logging state")
 			return
 		}
 
-		handler.handleEnable(enable, client)
-	}
+		// the payload is either a bare boolean or an EnableRequest carrying a selection
+		var request EnableRequest
+		if err := json.Unmarshal(msg.Payload, &request.Enable); err != nil {
+			if err := json.Unmarshal(msg.Payload, &request); err != nil {
+				handler.trace.Error().Stack().Err(err).Msg("unmarshal enable")
+				return
+			}
+		}
+
+		handler.handleEnable(request, client)
+	case handler.config.Topics.Start:
+		if client.IsReadOnly() {
+			handler.trace.Warn().Str("client", client.Id()).Msg("read only client tried to start a log session")
+			return
+		}
+
+		var request SessionRequest
+		if err := json.Unmarshal(msg.Payload, &request); err != nil {
+			handler.trace.Error().Stack().Err(err).Msg("unmarshal session request")
+			return
+		}
+
+		if request.Operator == "" {
+			request.Operator = client.Id()
+		}
+
+		if _, err := handler.StartSession(request); err != nil {
+			handler.trace.Warn().Err(err).Str("client", client.Id()).Msg("start session")
+		}
+		handler.notifyState(client)
+	case handler.config.Topics.Stop:
+		if client.IsReadOnly() {
+			handler.trace.Warn().Str("client", client.Id()).Msg("read only client tried to stop the log session")
+			return
+		}
+
+		if _, err := handler.StopSession(); err != nil {
+			handler.trace.Warn().Err(err).Str("client", client.Id()).Msg("stop session")
+		}
+		handler.notifyState(client)
+	case handler.config.Topics.Session:
+		observable.HandleSubscribe[*Session](&handler.sessionObservable, msg, client)
+	case handler.config.Topics.Sessions:
+		handler.handleSessions(client)
+	case handler.config.Topics.Trigger:
+		if client.IsReadOnly() {
+			handler.trace.Warn().Str("client", client.Id()).Msg("read only client tried to trigger logging")
+			return
+		}
 
+		var reason string
+		if err := json.Unmarshal(msg.Payload, &reason); err != nil || reason == "" {
+			reason = fmt.Sprintf("requested by %s", client.Id())
+		}
+
+		handler.Trigger(ManualTrigger, reason)
+		handler.notifyState(client)
+	case handler.config.Topics.Stats:
+		observable.HandleSubscribe[*PipelineStats](&handler.statsObservable, msg, client)
+	}
 }
 
-func (handler *LoggerHandler) handleEnable(enable bool, client wsModels.Client) error {
-	if !handler.verifySession(client) {
-		handler.trace.Warn().Str("source", client.Id()).Msg("tried to change running log session")
-		return fmt.Errorf("%s tried to change running log session of %s", client.Id(), handler.currentClient.Id())
+func (handler *LoggerHandler) handleEnable(request EnableRequest, client wsModels.Client) {
+	var err error
+	if request.Enable {
+		_, err = handler.StartSession(SessionRequest{Operator: client.Id(), Selection: request.Selection})
+	} else {
+		_, err = handler.StopSession()
 	}
 
-	handler.changeState(enable)
+	if err != nil {
+		handler.trace.Debug().Err(err).Str("client", client.Id()).Bool("enable", request.Enable).Msg("change state")
+	}
 
-	return nil
+	handler.notifyState(client)
 }
 
-func (handler *LoggerHandler) changeState(enable bool) {
+// StartSession creates a new session directory and starts every logger in it
+func (handler *LoggerHandler) StartSession(request SessionRequest) (Session, error) {
 	handler.isRunningMx.Lock()
 	defer handler.isRunningMx.Unlock()
-	if enable && !handler.isRunning {
-		handler.start()
-	} else if !enable && handler.isRunning {
-		handler.stop()
-	}
+
+	return handler.startSessionUnsafe(request, nil)
 }
 
-func (handler *LoggerHandler) verifySession(client wsModels.Client) bool {
-	if handler.currentClient == nil {
-		handler.currentClient = &client
+// startSessionUnsafe must be called with isRunningMx held, the replay loggables are logged before any new one
+func (handler *LoggerHandler) startSessionUnsafe(request SessionRequest, replay []Loggable) (Session, error) {
+	if handler.isRunning {
+		return *handler.session, ErrSessionRunning
 	}
 
-	//TODO: THIS BOUNDS THE LOGGING TO A PARTICULAR WS CONNECTION
-	return handler.currentClient.Id() == client.Id()
-}
+	handler.applyRetention()
+	if !handler.hasFreeSpace() {
+		handler.warn("log session not started, the disk is almost full")
+		return Session{}, ErrDiskFull
+	}
 
-func (handler *LoggerHandler) start() {
-	handler.trace.Info().Str("logger client", handler.currentClient.Id()).Msg("Started logging")
-	handler.loggableChan = make(chan Loggable)
-	currentTime := time.Now()
-	sessionDirName := fmt.Sprintf("%d_%d_%d - %d_%dh", currentTime.Day(), currentTime.Month(), currentTime.Year(), currentTime.Hour(), currentTime.Minute())
-	path := filepath.Join(handler.config.BasePath, sessionDirName)
-	os.MkdirAll(path, 0777)
+	filter, err := handler.newSessionFilter(request.Selection)
+	if err != nil {
+		handler.trace.Warn().Err(err).Msg("invalid session selection")
+		return Session{}, err
+	}
+
+	session := Session{
+		Name:       uniqueSessionName(handler.config.BasePath, request.Name),
+		Operator:   request.Operator,
+		Notes:      request.Notes,
+		AdeVersion: handler.adeVersion,
+		Selection:  request.Selection,
+		StartTime:  time.Now(),
+		Running:    true,
+	}
+
+	path := filepath.Join(handler.config.BasePath, session.Name)
+	if err := os.MkdirAll(path, 0777); err != nil {
+		handler.trace.Error().Err(err).Str("path", path).Msg("creating session directory")
+		return Session{}, err
+	}
 	os.Chmod(path, 0777)
 
-	activeLoggers := handler.createActiveLoggers(path)
+	if err := writeSession(path, session); err != nil {
+		handler.trace.Error().Err(err).Str("session", session.Name).Msg("writing session metadata")
+	}
+
+	handler.trace.Info().Str("session", session.Name).Str("operator", session.Operator).Msg("Started logging")
+	handler.loggableChan = make(chan Loggable, handler.queueSize)
+	handler.dropped = &atomic.Uint64{}
+	handler.statsDone = make(chan struct{})
+	activeLoggers := handler.createActiveLoggers(path, request.Selection)
 
-	go startBroadcastRoutine(activeLoggers, handler.loggableChan)
+	go startBroadcastRoutine(activeLoggers, filter, replay, handler.loggableChan)
+	go handler.startStatsRoutine(handler.loggableChan, handler.dropped, activeLoggers, handler.statsDone)
+	go handler.startDiskRoutine(handler.statsDone)
+	handler.session = &session
 	handler.isRunning = true
-	handler.notifyState()
+	handler.sessionObservable.Next(&session)
+
+	return session, nil
+}
+
+// StopSession stops the loggers and stores the end time of the session
+func (handler *LoggerHandler) StopSession() (Session, error) {
+	handler.isRunningMx.Lock()
+	defer handler.isRunningMx.Unlock()
+
+	return handler.stopSessionUnsafe()
+}
+
+// stopSessionUnsafe must be called with isRunningMx held
+func (handler *LoggerHandler) stopSessionUnsafe() (Session, error) {
+	if !handler.isRunning {
+		return Session{}, ErrNoSession
+	}
+
+	handler.trace.Info().Str("session", handler.session.Name).Msg("Stopped logging")
+	handler.isRunning = false
+	close(handler.loggableChan) // triggers loggers clean-up
+	close(handler.statsDone)
+
+	session := *handler.session
+	end := time.Now()
+	session.EndTime = &end
+	session.Running = false
+	session.updateDuration(end)
+	if err := writeSession(filepath.Join(handler.config.BasePath, session.Name), session); err != nil {
+		handler.trace.Error().Err(err).Str("session", session.Name).Msg("writing session metadata")
+	}
+
+	if handler.recorder != nil && handler.recorder.timer != nil {
+		handler.recorder.timer.Stop()
+		handler.recorder.session = ""
+	}
+
+	handler.session = nil
+	handler.sessionObservable.Next(&session)
+
+	return session, nil
 }
 
-func (handler *LoggerHandler) createActiveLoggers(path string) []ActiveLogger {
+func (handler *LoggerHandler) createActiveLoggers(path string, selection *Selection) []ActiveLogger {
 	activeLoggers := make([]ActiveLogger, 0)
 
-	for _, logger := range handler.loggers {
-		inputChan := logger.Start(path)
+	for name, logger := range handler.loggers {
+		if !isLoggerSelected(selection, name) {
+			continue
+		}
+
+		activeLogger := ActiveLogger{
+			Name:    name,
+			Ids:     logger.Ids(),
+			Queue:   make(chan Loggable, handler.queueSize),
+			Dropped: &atomic.Uint64{},
+		}
 
-		activeLoggers = append(activeLoggers, ActiveLogger{
-			Ids:   logger.Ids(),
-			Input: inputChan,
-		})
+		go startForwardRoutine(activeLogger.Queue, logger.Start(path))
+		activeLoggers = append(activeLoggers, activeLogger)
 	}
 
 	return activeLoggers
 }
 
-func startBroadcastRoutine(activeLoggers []ActiveLogger, generalInput <-chan Loggable) {
-	for loggable := range generalInput {
+// startBroadcastRoutine waits for each logger to take the replay loggables, so none of them is dropped,
+// and then forwards the general input. The general input keeps being read while the replay is handed over,
+// what arrives meanwhile is queued behind the replay so it doesn't fill the input queue and get dropped
+func startBroadcastRoutine(activeLoggers []ActiveLogger, filter *sessionFilter, replay []Loggable, generalInput <-chan Loggable) {
+	input := generalInput
+	pending := replay
+	for len(pending) > 0 {
+		loggable := pending[0]
+		pending = pending[1:]
+		if !filter.allows(loggable.Id()) {
+			continue
+		}
+
 		for _, logger := range activeLoggers {
-			if logger.Ids.Has(loggable.Id()) {
-				logger.Input <- loggable
+			if !logger.Ids.Has(loggable.Id()) {
+				continue
+			}
+
+			for sent := false; !sent; {
+				select {
+				case logger.Queue <- loggable:
+					sent = true
+				case live, ok := <-input:
+					if !ok {
+						// the session stopped, a nil channel is never selected again
+						input = nil
+						continue
+					}
+					pending = append(pending, live)
+				}
 			}
 		}
 	}
 
-	defer func() {
-		for _, logger := range activeLoggers {
-			close(logger.Input)
+	if input == nil {
+		closeQueues(activeLoggers)
+		return
+	}
+
+	for loggable := range generalInput {
+		if !filter.allows(loggable.Id()) {
+			continue
 		}
-	}()
-}
 
-func (handler *LoggerHandler) stop() {
-	handler.trace.Info().Str("logger session", handler.currentClient.Id()).Msg("Stoped logging")
-	handler.isRunning = false
-	close(handler.loggableChan) // triggers loggers clean-up
-	handler.notifyState()
-	handler.currentClient = nil
-}
+		for _, logger := range activeLoggers {
+			if !logger.Ids.Has(loggable.Id()) {
+				continue
+			}
 
-func (handler *LoggerHandler) NotifyDisconnect(client wsModels.Client) {
-	handler.trace.Debug().Str("session", client.Id()).Msg("notify disconnect")
-	if handler.verifySession(client) {
-		handler.isRunningMx.Lock()
-		if handler.isRunning {
-			handler.stop()
+			select {
+			case logger.Queue <- loggable:
+			default:
+				logger.Dropped.Add(1)
+			}
 		}
-		handler.isRunningMx.Unlock()
+	}
 
-		handler.currentClient = nil
+	closeQueues(activeLoggers)
+}
+
+func closeQueues(activeLoggers []ActiveLogger) {
+	for _, logger := range activeLoggers {
+		close(logger.Queue)
 	}
 }
 
-func (handler *LoggerHandler) notifyState() error {
-	//TODO: check error handling
-	if handler.currentClient == nil {
-		return nil
+// startForwardRoutine feeds the logger at its own pace, closing its input once the queue is drained
+func startForwardRoutine(queue <-chan Loggable, input chan<- Loggable) {
+	for loggable := range queue {
+		input <- loggable
 	}
 
-	msgBuf, err := wsModels.NewMessageBuf(ResponseTopic, handler.isRunning)
+	close(input)
+}
 
+// Sessions lists the recorded sessions, marking the one currently running
+func (handler *LoggerHandler) Sessions() ([]Session, error) {
+	sessions, err := ListSessions(handler.config.BasePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = handler.currentClient.Write(msgBuf)
+	handler.isRunningMx.Lock()
+	defer handler.isRunningMx.Unlock()
+	for i := range sessions {
+		sessions[i].Running = handler.isRunning && sessions[i].Name == handler.session.Name
+	}
+
+	return sessions, nil
+}
 
+func (handler *LoggerHandler) handleSessions(client wsModels.Client) {
+	sessions, err := handler.Sessions()
 	if err != nil {
-		return err
+		handler.trace.Error().Err(err).Msg("listing sessions")
+		return
 	}
 
-	return nil
+	if err := client.WriteMessage(handler.config.Topics.Sessions, sessions); err != nil {
+		handler.trace.Error().Err(err).Str("client", client.Id()).Msg("sending sessions")
+	}
+}
+
+func (handler *LoggerHandler) notifyState(client wsModels.Client) error {
+	handler.isRunningMx.Lock()
+	isRunning := handler.isRunning
+	handler.isRunningMx.Unlock()
+
+	return client.WriteMessage(ResponseTopic, isRunning)
 }
 
 func (handler *LoggerHandler) HandlerName() string {
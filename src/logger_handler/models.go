@@ -1,18 +1,49 @@
 package logger_handler
 
-import "github.com/HyperloopUPV-H8/Backend-H8/common"
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+)
 
 type Logger interface {
 	Ids() common.Set[string]
 	Start(basePath string) chan<- Loggable
 }
 
+// CSVLogger is a Logger writing CSVFiles, the LoggerHandler sets their options from its config
+type CSVLogger interface {
+	SetCSVOptions(options CSVOptions)
+}
+
+// ActiveLogger buffers the entries of a running logger so a slow logger doesn't block the rest
 type ActiveLogger struct {
-	Ids   common.Set[string]
-	Input chan<- Loggable
+	Name    string
+	Ids     common.Set[string]
+	Queue   chan Loggable
+	Dropped *atomic.Uint64
 }
 
 type Loggable interface {
 	Id() string
 	Log() []string
 }
+
+// TimestampFormat is used for every timestamp written by the loggers so files can be parsed without custom code
+const TimestampFormat = time.RFC3339Nano
+
+func FormatTimestamp(timestamp time.Time) string {
+	return timestamp.Format(TimestampFormat)
+}
+
+// FormatJSON encodes structured columns, falling back to the Go representation if the value can't be encoded
+func FormatJSON(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
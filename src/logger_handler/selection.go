@@ -0,0 +1,128 @@
+package logger_handler
+
+import (
+	"fmt"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+)
+
+// Selection narrows what a session logs, empty fields select everything.
+// Boards, Packets and Measurements only filter the data loggables listed in the IdCatalog,
+// orders, messages and the rest are always logged by the selected loggers
+type Selection struct {
+	Loggers      []string `json:"loggers,omitempty"`
+	Boards       []string `json:"boards,omitempty"`
+	Packets      []uint16 `json:"packets,omitempty"`
+	Measurements []string `json:"measurements,omitempty"`
+	// Decimation logs one of every n loggables of each id, a packet id or a measurement id
+	Decimation map[string]uint `json:"decimation,omitempty"`
+}
+
+// IdCatalog tells the handler which loggable ids belong to each board and packet
+type IdCatalog struct {
+	// Boards maps each board to the ids of its packets
+	Boards map[string][]uint16
+	// Packets maps each packet id to the ids of the loggables it produces
+	Packets map[uint16][]string
+}
+
+// sessionFilter is only used from the broadcast routine, so the decimation counters need no lock
+type sessionFilter struct {
+	// dataIds are the ids subject to the selection, only the allowed ones are logged when restricted
+	dataIds    common.Set[string]
+	allowed    common.Set[string]
+	restricted bool
+	decimation map[string]uint
+	counters   map[string]uint
+}
+
+func (handler *LoggerHandler) newSessionFilter(selection *Selection) (*sessionFilter, error) {
+	if selection == nil {
+		return nil, nil
+	}
+
+	for _, name := range selection.Loggers {
+		if _, ok := handler.loggers[name]; !ok {
+			return nil, fmt.Errorf("unknown logger %s", name)
+		}
+	}
+
+	filter := &sessionFilter{
+		dataIds:    common.NewSet[string](),
+		allowed:    common.NewSet[string](),
+		decimation: selection.Decimation,
+		counters:   make(map[string]uint),
+	}
+
+	for _, ids := range handler.catalog.Packets {
+		for _, id := range ids {
+			filter.dataIds.Add(id)
+		}
+	}
+
+	if len(selection.Boards) == 0 && len(selection.Packets) == 0 && len(selection.Measurements) == 0 {
+		return filter, nil
+	}
+
+	packets := append([]uint16{}, selection.Packets...)
+	for _, board := range selection.Boards {
+		boardPackets, ok := handler.catalog.Boards[board]
+		if !ok {
+			return nil, fmt.Errorf("unknown board %s", board)
+		}
+		packets = append(packets, boardPackets...)
+	}
+
+	filter.restricted = true
+	for _, packet := range packets {
+		ids, ok := handler.catalog.Packets[packet]
+		if !ok {
+			return nil, fmt.Errorf("unknown packet %d", packet)
+		}
+		for _, id := range ids {
+			filter.allowed.Add(id)
+		}
+	}
+
+	for _, measurement := range selection.Measurements {
+		if !filter.dataIds.Has(measurement) {
+			return nil, fmt.Errorf("unknown measurement %s", measurement)
+		}
+		filter.allowed.Add(measurement)
+	}
+
+	return filter, nil
+}
+
+func (filter *sessionFilter) allows(id string) bool {
+	if filter == nil {
+		return true
+	}
+
+	if filter.restricted && filter.dataIds.Has(id) && !filter.allowed.Has(id) {
+		return false
+	}
+
+	every := filter.decimation[id]
+	if every <= 1 {
+		return true
+	}
+
+	count := filter.counters[id]
+	filter.counters[id] = (count + 1) % every
+	return count == 0
+}
+
+func isLoggerSelected(selection *Selection, name string) bool {
+	if selection == nil || len(selection.Loggers) == 0 {
+		return true
+	}
+
+	for _, selected := range selection.Loggers {
+		if selected == name {
+			return true
+		}
+	}
+
+	return false
+}
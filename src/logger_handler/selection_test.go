@@ -0,0 +1,57 @@
+package logger_handler
+
+import "testing"
+
+func TestSessionFilter(t *testing.T) {
+	handler := &LoggerHandler{
+		loggers: map[string]Logger{"values": nil},
+		catalog: IdCatalog{
+			Boards:  map[string][]uint16{"VCU": {1}, "BMS": {2}},
+			Packets: map[uint16][]string{1: {"1", "speed", "brake"}, 2: {"2", "voltage"}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		selection *Selection
+		ids       []string
+		want      []bool
+	}{
+		{"no selection", nil, []string{"speed", "voltage", "order"}, []bool{true, true, true}},
+		{"board", &Selection{Boards: []string{"VCU"}}, []string{"1", "speed", "2", "voltage"}, []bool{true, true, false, false}},
+		{"measurement", &Selection{Measurements: []string{"voltage"}}, []string{"speed", "2", "voltage"}, []bool{false, false, true}},
+		{"ids outside the catalog", &Selection{Packets: []uint16{2}}, []string{"order", "info"}, []bool{true, true}},
+		{"decimation", &Selection{Decimation: map[string]uint{"speed": 3}}, []string{"speed", "speed", "speed", "speed", "brake"}, []bool{true, false, false, true, true}},
+		{"decimation per id", &Selection{Decimation: map[string]uint{"speed": 2, "brake": 2}}, []string{"speed", "brake", "speed", "brake", "speed"}, []bool{true, true, false, false, true}},
+		{"decimation of one", &Selection{Decimation: map[string]uint{"speed": 1}}, []string{"speed", "speed"}, []bool{true, true}},
+		{"decimation after selection", &Selection{Boards: []string{"BMS"}, Decimation: map[string]uint{"speed": 2, "voltage": 2}}, []string{"speed", "voltage", "voltage", "voltage"}, []bool{false, true, false, true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := handler.newSessionFilter(test.selection)
+			if err != nil {
+				t.Fatalf("creating filter: %s", err)
+			}
+
+			for i, id := range test.ids {
+				if got := filter.allows(id); got != test.want[i] {
+					t.Fatalf("loggable %d (%s): expected %v, got %v", i, id, test.want[i], got)
+				}
+			}
+		})
+	}
+
+	t.Run("unknown names are rejected", func(t *testing.T) {
+		for _, selection := range []Selection{
+			{Loggers: []string{"missing"}},
+			{Boards: []string{"missing"}},
+			{Packets: []uint16{3}},
+			{Measurements: []string{"missing"}},
+		} {
+			if _, err := handler.newSessionFilter(&selection); err == nil {
+				t.Fatalf("expected error for %+v", selection)
+			}
+		}
+	})
+}
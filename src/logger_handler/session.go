@@ -0,0 +1,160 @@
+package logger_handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const SessionMetadataFile = "session.json"
+
+var ErrSessionRunning = errors.New("a log session is already running")
+var ErrNoSession = errors.New("no log session is running")
+
+type SessionRequest struct {
+	Name     string `json:"name,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Notes    string `json:"notes,omitempty"`
+	// Selection is nil to log everything
+	Selection *Selection `json:"selection,omitempty"`
+}
+
+// EnableRequest is the object form of the enable message, the bare boolean form logs everything
+type EnableRequest struct {
+	Enable    bool       `json:"enable"`
+	Selection *Selection `json:"selection,omitempty"`
+}
+
+type Session struct {
+	Name       string     `json:"name"`
+	Operator   string     `json:"operator"`
+	Notes      string     `json:"notes"`
+	AdeVersion string     `json:"adeVersion"`
+	Selection  *Selection `json:"selection,omitempty"`
+	StartTime  time.Time  `json:"startTime"`
+	EndTime    *time.Time `json:"endTime,omitempty"`
+	// Size and DurationMs are computed when listing, they are not stored
+	Size       int64 `json:"size"`
+	DurationMs int64 `json:"durationMs"`
+	Running    bool  `json:"running"`
+}
+
+func defaultSessionName(now time.Time) string {
+	return fmt.Sprintf("%d_%d_%d - %d_%dh", now.Day(), now.Month(), now.Year(), now.Hour(), now.Minute())
+}
+
+// uniqueSessionName sanitizes the requested name and appends a counter if a session with that name already exists
+func uniqueSessionName(basePath string, name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+
+	if name == "" || name == "." || name == ".." {
+		name = defaultSessionName(time.Now())
+	}
+
+	candidate := name
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(basePath, candidate)); errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s_%d", name, i)
+	}
+}
+
+func writeSession(path string, session Session) error {
+	data, err := json.MarshalIndent(session, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(path, SessionMetadataFile), data, 0777)
+}
+
+// readSession falls back to the directory info for sessions recorded without metadata
+func readSession(path string) (Session, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Session{}, err
+	}
+
+	session := Session{Name: filepath.Base(path), StartTime: info.ModTime()}
+	data, err := os.ReadFile(filepath.Join(path, SessionMetadataFile))
+	if err == nil {
+		err = json.Unmarshal(data, &session)
+	} else if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	if err != nil {
+		return Session{}, err
+	}
+
+	session.Name = filepath.Base(path)
+	session.Size, err = dirSize(path)
+	return session, err
+}
+
+func (session *Session) updateDuration(now time.Time) {
+	end := now
+	if session.EndTime != nil {
+		end = *session.EndTime
+	}
+	session.DurationMs = end.Sub(session.StartTime).Milliseconds()
+}
+
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		size += info.Size()
+		return nil
+	})
+
+	return size, err
+}
+
+// ListSessions returns every session under basePath, newest first
+func ListSessions(basePath string) ([]Session, error) {
+	entries, err := os.ReadDir(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessions := make([]Session, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		session, err := readSession(filepath.Join(basePath, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		session.updateDuration(now)
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.After(sessions[j].StartTime)
+	})
+
+	return sessions, nil
+}
@@ -0,0 +1,82 @@
+package logger_handler
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type LoggerStats struct {
+	Logger   string `json:"logger"`
+	Queued   int    `json:"queued"`
+	Capacity int    `json:"capacity"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+// PipelineStats describes the state of the logging queues, the general queue feeds every logger queue
+type PipelineStats struct {
+	Queued    int           `json:"queued"`
+	Capacity  int           `json:"capacity"`
+	Dropped   uint64        `json:"dropped"`
+	Loggers   []LoggerStats `json:"loggers"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// a queue is considered behind once it is this fraction full
+const behindRatio = 0.75
+
+// startStatsRoutine publishes the pipeline stats every interval and warns when entries are dropped
+// or queues are close to full
+func (handler *LoggerHandler) startStatsRoutine(input chan Loggable, dropped *atomic.Uint64, activeLoggers []ActiveLogger, done <-chan struct{}) {
+	ticker := time.NewTicker(handler.statsInterval)
+	defer ticker.Stop()
+
+	var lastDropped uint64
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		stats := PipelineStats{
+			Queued:    len(input),
+			Capacity:  cap(input),
+			Dropped:   dropped.Load(),
+			Loggers:   make([]LoggerStats, 0, len(activeLoggers)),
+			Timestamp: time.Now(),
+		}
+
+		totalDropped := stats.Dropped
+		behind := isBehind(stats.Queued, stats.Capacity)
+		for _, logger := range activeLoggers {
+			loggerStats := LoggerStats{
+				Logger:   logger.Name,
+				Queued:   len(logger.Queue),
+				Capacity: cap(logger.Queue),
+				Dropped:  logger.Dropped.Load(),
+			}
+			totalDropped += loggerStats.Dropped
+			behind = behind || isBehind(loggerStats.Queued, loggerStats.Capacity)
+			stats.Loggers = append(stats.Loggers, loggerStats)
+		}
+
+		handler.statsObservable.Next(&stats)
+
+		if totalDropped > lastDropped {
+			handler.warn(fmt.Sprintf("logging is falling behind, %d entries dropped in the last %s", totalDropped-lastDropped, handler.statsInterval))
+		} else if behind {
+			handler.warn("logging is falling behind, queues are almost full")
+		}
+		lastDropped = totalDropped
+	}
+}
+
+func isBehind(queued int, capacity int) bool {
+	return capacity > 0 && float64(queued) >= float64(capacity)*behindRatio
+}
+
+func (handler *LoggerHandler) warn(msg string) {
+	handler.trace.Warn().Msg(msg)
+	handler.onWarning(msg)
+}
@@ -0,0 +1,203 @@
+package logger_handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/HyperloopUPV-H8/Backend-H8/condition"
+	"github.com/HyperloopUPV-H8/Backend-H8/packet"
+)
+
+const (
+	DEFAULT_TRIGGER_BUFFER_SIZE = 100000
+	DEFAULT_PRE_TRIGGER         = "30s"
+	DEFAULT_POST_TRIGGER        = "30s"
+)
+
+const (
+	FaultTrigger     = "fault"
+	ThresholdTrigger = "threshold"
+	OrderTrigger     = "order"
+	ManualTrigger    = "manual"
+)
+
+type bufferedLoggable struct {
+	timestamp time.Time
+	loggable  Loggable
+}
+
+// recorder keeps the loggables received while no session is running, so a trigger can dump what happened before it.
+// It is guarded by the handler isRunningMx
+type recorder struct {
+	buffer      common.RingBuf[bufferedLoggable]
+	preTrigger  time.Duration
+	postTrigger time.Duration
+	orders      common.Set[uint16]
+	thresholds  []condition.Condition
+	// thresholdsMet remembers the last evaluation of each threshold to trigger only when it starts being met
+	thresholdsMet []bool
+
+	// session is the name of the session started by a trigger, timer stops it after the post-trigger window
+	session string
+	timer   *time.Timer
+}
+
+func newRecorder(config TriggerConfig, parse func(value string, fallback string) time.Duration) *recorder {
+	if !config.Enable {
+		return nil
+	}
+
+	bufferSize := config.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DEFAULT_TRIGGER_BUFFER_SIZE
+	}
+
+	orders := common.NewSet[uint16]()
+	for _, order := range config.Orders {
+		orders.Add(order)
+	}
+
+	return &recorder{
+		buffer:        common.NewRingBuf[bufferedLoggable](bufferSize),
+		preTrigger:    parse(config.PreTrigger, DEFAULT_PRE_TRIGGER),
+		postTrigger:   parse(config.PostTrigger, DEFAULT_POST_TRIGGER),
+		orders:        orders,
+		thresholds:    config.Thresholds,
+		thresholdsMet: make([]bool, len(config.Thresholds)),
+	}
+}
+
+// coverage returns how far back the buffer goes and whether it has already evicted part of the pre-trigger window,
+// which happens when buffer_size loggables arrive faster than pre_trigger
+func (recorder *recorder) coverage(now time.Time) (time.Duration, bool) {
+	oldest := recorder.buffer.Items()[0]
+	if oldest.loggable == nil {
+		return recorder.preTrigger, false
+	}
+
+	covered := now.Sub(oldest.timestamp)
+	return covered, covered < recorder.preTrigger
+}
+
+// drain returns the loggables inside the pre-trigger window from the oldest to the newest and empties the buffer
+func (recorder *recorder) drain(now time.Time) []Loggable {
+	loggables := make([]Loggable, 0)
+	for _, entry := range recorder.buffer.Items() {
+		if entry.loggable == nil || now.Sub(entry.timestamp) > recorder.preTrigger {
+			continue
+		}
+		loggables = append(loggables, entry.loggable)
+	}
+
+	recorder.buffer = common.NewRingBuf[bufferedLoggable](recorder.buffer.Len())
+	return loggables
+}
+
+// Trigger dumps the pre-trigger window into a new session which stops once the post-trigger window elapses.
+// Triggering again during the post-trigger window extends it, a session started by hand is left untouched
+func (handler *LoggerHandler) Trigger(cause string, detail string) {
+	if handler.recorder == nil {
+		return
+	}
+
+	handler.isRunningMx.Lock()
+	defer handler.isRunningMx.Unlock()
+
+	recorder := handler.recorder
+	if handler.isRunning {
+		if recorder.session != "" && handler.session.Name == recorder.session {
+			handler.trace.Info().Str("cause", cause).Str("detail", detail).Msg("extending triggered session")
+			recorder.timer.Reset(recorder.postTrigger)
+		}
+		return
+	}
+
+	now := time.Now()
+	if covered, truncated := recorder.coverage(now); truncated {
+		handler.warn(fmt.Sprintf("trigger buffer only covers the last %s of the %s pre-trigger window, raise trigger.buffer_size", covered.Round(time.Millisecond), recorder.preTrigger))
+	}
+
+	replay := recorder.drain(now)
+	session, err := handler.startSessionUnsafe(SessionRequest{
+		Name:     fmt.Sprintf("trigger_%s", cause),
+		Operator: "backend",
+		Notes:    detail,
+	}, replay)
+	if err != nil {
+		handler.trace.Error().Err(err).Str("cause", cause).Msg("starting triggered session")
+		return
+	}
+
+	handler.trace.Info().Str("cause", cause).Str("detail", detail).Int("replayed", len(replay)).Msg("logging triggered")
+	handler.warn(fmt.Sprintf("logging triggered by %s: %s", cause, detail))
+
+	recorder.session = session.Name
+	recorder.timer = time.AfterFunc(recorder.postTrigger, func() {
+		handler.stopTriggered(session.Name)
+	})
+}
+
+func (handler *LoggerHandler) stopTriggered(name string) {
+	handler.isRunningMx.Lock()
+	defer handler.isRunningMx.Unlock()
+
+	if !handler.isRunning || handler.session.Name != name {
+		return
+	}
+
+	if _, err := handler.stopSessionUnsafe(); err != nil {
+		handler.trace.Error().Err(err).Str("session", name).Msg("stopping triggered session")
+	}
+}
+
+// NotifyFault triggers logging when a board reports a fault and trigger.on_fault is set
+func (handler *LoggerHandler) NotifyFault(board string) {
+	if handler.recorder == nil || !handler.config.Trigger.OnFault {
+		return
+	}
+
+	handler.Trigger(FaultTrigger, fmt.Sprintf("fault in %s", board))
+}
+
+// NotifyOrder triggers logging when one of the trigger.orders is sent
+func (handler *LoggerHandler) NotifyOrder(id uint16) {
+	if handler.recorder == nil || !handler.recorder.orders.Has(id) {
+		return
+	}
+
+	handler.Trigger(OrderTrigger, fmt.Sprintf("order %d sent", id))
+}
+
+// UpdateValues triggers logging when a threshold goes from not met to met
+func (handler *LoggerHandler) UpdateValues(values map[string]packet.Value) {
+	if handler.recorder == nil || len(handler.recorder.thresholds) == 0 {
+		return
+	}
+
+	crossed := make([]condition.Condition, 0)
+
+	handler.isRunningMx.Lock()
+	for i, threshold := range handler.recorder.thresholds {
+		value, ok := values[threshold.Measurement]
+		if !ok {
+			continue
+		}
+
+		met, err := threshold.Evaluate(value)
+		if err != nil {
+			handler.trace.Error().Err(err).Str("threshold", threshold.String()).Msg("evaluating threshold")
+			continue
+		}
+
+		if met && !handler.recorder.thresholdsMet[i] {
+			crossed = append(crossed, threshold)
+		}
+		handler.recorder.thresholdsMet[i] = met
+	}
+	handler.isRunningMx.Unlock()
+
+	for _, threshold := range crossed {
+		handler.Trigger(ThresholdTrigger, threshold.String())
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"crypto/sha256"
 	"flag"
 	"fmt"
 	"net"
@@ -10,13 +11,19 @@ import (
 	"path"
 	"runtime"
 	"strings"
+	"time"
 
 	blcuPackage "github.com/HyperloopUPV-H8/Backend-H8/blcu"
+	"github.com/HyperloopUPV-H8/Backend-H8/blcu/firmware"
+	"github.com/HyperloopUPV-H8/Backend-H8/columnar_logger"
 	"github.com/HyperloopUPV-H8/Backend-H8/common"
 	"github.com/HyperloopUPV-H8/Backend-H8/connection_transfer"
 	"github.com/HyperloopUPV-H8/Backend-H8/data_transfer"
+	"github.com/HyperloopUPV-H8/Backend-H8/emergency_transfer"
 	"github.com/HyperloopUPV-H8/Backend-H8/excel"
 	"github.com/HyperloopUPV-H8/Backend-H8/excel/ade"
+	"github.com/HyperloopUPV-H8/Backend-H8/file_logger"
+	"github.com/HyperloopUPV-H8/Backend-H8/history_transfer"
 	"github.com/HyperloopUPV-H8/Backend-H8/info"
 	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
 	protection_logger "github.com/HyperloopUPV-H8/Backend-H8/message_logger"
@@ -25,7 +32,9 @@ import (
 	"github.com/HyperloopUPV-H8/Backend-H8/order_transfer"
 	"github.com/HyperloopUPV-H8/Backend-H8/packet_logger"
 	"github.com/HyperloopUPV-H8/Backend-H8/pod_data"
+	"github.com/HyperloopUPV-H8/Backend-H8/procedure"
 	"github.com/HyperloopUPV-H8/Backend-H8/server"
+	"github.com/HyperloopUPV-H8/Backend-H8/sqlite_logger"
 	"github.com/HyperloopUPV-H8/Backend-H8/state_space_logger"
 	"github.com/HyperloopUPV-H8/Backend-H8/update_factory"
 	"github.com/HyperloopUPV-H8/Backend-H8/value_logger"
@@ -39,6 +48,8 @@ import (
 	trace "github.com/rs/zerolog/log"
 )
 
+const EMERGENCY_CHAN_BUF = 10
+
 var traceLevel = flag.String("trace", "info", "set the trace level (\"fatal\", \"error\", \"warn\", \"info\", \"debug\", \"trace\")")
 var traceFile = flag.String("log", "trace.json", "set the trace log file")
 
@@ -102,7 +113,9 @@ func main() {
 		trace.Fatal().Err(err).Msg("creating vehicleOrders")
 	}
 
-	orderTransfer, orderChannel := order_transfer.New()
+	orderTransfer, orderChannel := order_transfer.New(config.Orders)
+
+	emergencyStops := make(chan vehicle_models.EmergencyStop, EMERGENCY_CHAN_BUF)
 
 	vehicle := vehicle.New(vehicle.VehicleConstructorArgs{
 		PodData: podData,
@@ -115,8 +128,27 @@ func main() {
 			}
 			connectionTransfer.Update(board, isConnected)
 		},
+		// called from the vehicle listen routine, a stalled consumer must not stop the packet reading
+		OnEmergencyStop: func(record vehicle_models.EmergencyStop) {
+			select {
+			case emergencyStops <- record:
+			default:
+				trace.Error().Str("source", record.Source).Str("reason", record.Reason).Msg("emergency stop record dropped")
+			}
+		},
 	})
 
+	uploadableBords := common.Filter(common.Keys(info.Addresses.Boards), func(item string) bool {
+		return item != config.Excel.Parse.Global.BLCUAddressKey
+	})
+
+	firmwareStore, err := firmware.NewStore(config.BLCU.Firmware.Path, blcuPackage.FlashMemorySize)
+
+	if err != nil {
+		trace.Fatal().Err(err).Msg("creating firmware store")
+	}
+	firmwareStore.SetBoards(uploadableBords)
+
 	var blcu blcuPackage.BLCU
 	blcuAddr, useBlcu := info.Addresses.Boards["BLCU"]
 
@@ -127,6 +159,7 @@ func main() {
 		}, info.BoardIds, config.BLCU)
 
 		blcu.SetSendOrder(vehicle.SendOrder)
+		blcu.SetFirmwareStore(firmwareStore)
 	}
 
 	vehicleUpdates := make(chan vehicle_models.PacketUpdate, 1)
@@ -140,12 +173,14 @@ func main() {
 	go dataTransfer.Run()
 
 	messageTransfer := message_transfer.New(config.Messages)
+	historyTransfer := history_transfer.New(config.History)
 
 	packetLogger := packet_logger.NewPacketLogger(podData.Boards, config.PacketLogger)
 	valueLogger := value_logger.NewValueLogger(podData.Boards, config.ValueLogger)
 	orderLogger := order_logger.NewOrderLogger(podData.Boards, config.OrderLogger)
 	protectionLogger := protection_logger.NewMessageLogger(config.Vehicle.Messages.InfoIdKey, config.Vehicle.Messages.FaultIdKey, config.Vehicle.Messages.WarningIdKey, config.ProtectionLogger)
 	stateSpaceLogger := state_space_logger.NewStateSpaceLogger(info.MessageIds.StateSpace)
+	procedureLogger := file_logger.NewFileLogger("procedureLogger", procedureLoggerIds(), procedure.LoggableStepHeader, config.ProcedureLogger)
 
 	loggers := map[string]logger_handler.Logger{
 		"packets":     &packetLogger,
@@ -153,27 +188,70 @@ func main() {
 		"orders":      &orderLogger,
 		"protections": &protectionLogger,
 		"stateSpace":  &stateSpaceLogger,
+		"procedures":  &procedureLogger,
+	}
+
+	if config.ColumnarLogger.Enable {
+		columnarLogger := columnar_logger.NewColumnarLogger(podData.Boards, config.ColumnarLogger)
+		loggers["columnar"] = &columnarLogger
+	}
+
+	var sqliteLogger sqlite_logger.SQLiteLogger
+	if config.SQLiteLogger.Enable {
+		sqliteLogger = sqlite_logger.NewSQLiteLogger(loggerIds(&packetLogger, &valueLogger, &orderLogger, &protectionLogger), config.SQLiteLogger)
+		loggers["sqlite"] = &sqliteLogger
 	}
 
 	loggerHandler := logger_handler.NewLoggerHandler(loggers, config.LoggerHandler)
+	loggerHandler.SetCatalog(loggerCatalog(dataOnlyPodData.Boards))
+	loggerHandler.SetAdeVersion(getAdeVersion(excel.DownloadConfig(config.Excel.Download)))
+	loggerHandler.SetOnWarning(func(msg string) {
+		messageTransfer.SendMessage(vehicle_models.NewBackendWarning("logger", msg))
+	})
+
+	orderTransfer.SetOnStateOrderEvent(func(event vehicle_models.StateOrderEvent) {
+		loggerHandler.Log(order_logger.LoggableStateOrderEvent(event))
+	})
+
+	emergencyTransfer := emergency_transfer.New(config.Emergency)
+	emergencyTransfer.SetEmergencyStop(vehicle.EmergencyStop)
+
+	procedureRunner := procedure.New(config.Procedures)
+	procedureRunner.SetSendOrder(func(order vehicle_models.Order) error {
+		if err := orderTransfer.CheckInterlocks(order); err != nil {
+			return err
+		}
+		return sendOrder(order, &vehicle, &loggerHandler)
+	})
+	procedureRunner.SetStateOrderCheck(orderTransfer.IsStateOrderEnabled)
+	procedureRunner.SetOnLog(func(step procedure.LoggableStep) { loggerHandler.Log(step) })
 
 	websocketBroker := ws_handle.New()
 	defer websocketBroker.Close()
 
 	if useBlcu {
-		websocketBroker.RegisterHandle(&blcu, config.BLCU.Topics.Upload, config.BLCU.Topics.Download)
+		websocketBroker.RegisterHandle(&blcu, config.BLCU.Topics.Upload, config.BLCU.Topics.Download, config.BLCU.Topics.Cancel, config.BLCU.Topics.Progress)
 	}
 
 	websocketBroker.RegisterHandle(&connectionTransfer, config.Connections.UpdateTopic, "connection/update")
 	websocketBroker.RegisterHandle(&dataTransfer, "podData/update")
-	websocketBroker.RegisterHandle(&loggerHandler, config.LoggerHandler.Topics.Enable)
+	websocketBroker.RegisterHandle(&loggerHandler, config.LoggerHandler.Topics.Enable, config.LoggerHandler.Topics.Start, config.LoggerHandler.Topics.Stop, config.LoggerHandler.Topics.Session, config.LoggerHandler.Topics.Sessions, config.LoggerHandler.Topics.Stats, config.LoggerHandler.Topics.Trigger)
+	websocketBroker.RegisterHandle(&historyTransfer, config.History.Topic)
 	websocketBroker.RegisterHandle(&messageTransfer, "message/update")
-	websocketBroker.RegisterHandle(&orderTransfer, config.Orders.SendTopic, "order/stateOrders")
+	websocketBroker.RegisterHandle(&orderTransfer, config.Orders.SendTopic, "order/stateOrders", config.Orders.StateOrdersQueryTopic)
+	websocketBroker.RegisterHandle(&emergencyTransfer, config.Emergency.StopTopic, config.Emergency.UpdateTopic)
+	websocketBroker.RegisterHandle(&procedureRunner, config.Procedures.Topics.Run, config.Procedures.Topics.Abort, config.Procedures.Topics.List, config.Procedures.Topics.Progress)
+
+	if config.LoggerHandler.AutoStart {
+		if _, err := loggerHandler.StartSession(logger_handler.SessionRequest{Operator: "backend"}); err != nil {
+			trace.Error().Err(err).Msg("auto starting log session")
+		}
+	}
 
 	go vehicle.Listen(vehicleUpdates, vehicleTransmittedOrders, vehicleProtections, blcuAckChan, stateOrdersChan, stateSpaceChan)
 
-	go startPacketUpdateRoutine(vehicleUpdates, &dataTransfer, &loggerHandler)
-	go startMessagesRoutine(vehicleProtections, &messageTransfer, &loggerHandler)
+	go startPacketUpdateRoutine(vehicleUpdates, &dataTransfer, &historyTransfer, &loggerHandler, &procedureRunner, &orderTransfer, config.ColumnarLogger.Enable)
+	go startMessagesRoutine(vehicleProtections, &messageTransfer, &loggerHandler, &procedureRunner)
 	go startOrderRoutine(orderChannel, &vehicle, &loggerHandler)
 
 	go func() {
@@ -191,6 +269,13 @@ func main() {
 		}()
 	}
 
+	go func() {
+		for record := range emergencyStops {
+			emergencyTransfer.Record(record)
+			loggerHandler.Log(order_logger.LoggableEmergencyStop(record))
+		}
+	}()
+
 	go func() {
 		for stateSpace := range stateSpaceChan {
 			for _, row := range stateSpace {
@@ -211,14 +296,24 @@ func main() {
 		}
 	}()
 
-	uploadableBords := common.Filter(common.Keys(info.Addresses.Boards), func(item string) bool {
-		return item != config.Excel.Parse.Global.BLCUAddressKey
-	})
+	handlers := firmwareStore.Handlers(config.BLCU.Firmware.Endpoint)
+	for path, handler := range loggerHandler.Handlers(config.LoggerHandler.Endpoint) {
+		handlers[path] = handler
+	}
+	if config.SQLiteLogger.Enable {
+		for path, handler := range sqliteLogger.Handlers(config.SQLiteLogger.Endpoint, config.LoggerHandler.BasePath) {
+			handlers[path] = handler
+		}
+	}
 
 	endpointData := server.EndpointData{
 		PodData:           dataOnlyPodData,
 		OrderData:         vehicleOrders,
 		ProgramableBoards: uploadableBords,
+		Handlers:          handlers,
+	}
+	if useBlcu {
+		endpointData.OperatorHandlers = blcu.Handlers(config.BLCU.Endpoint)
 	}
 
 	serverHandler, err := server.New(&websocketBroker, endpointData, config.Server)
@@ -342,17 +437,62 @@ func getConfig(path string) Config {
 		trace.Fatal().Stack().Err(decodeErr).Msg("error unmarshaling toml file")
 	}
 
+	if err := config.Server.Validate(); err != nil {
+		trace.Fatal().Err(err).Msg("invalid server config")
+	}
+
 	return config
 }
 
-func startPacketUpdateRoutine(vehicleUpdates <-chan vehicle_models.PacketUpdate, dataTransfer *data_transfer.DataTransfer, loggerHandler *logger_handler.LoggerHandler) {
+// getAdeVersion identifies the ADE by the checksum of the downloaded file
+func getAdeVersion(config excel.DownloadConfig) string {
+	data, err := os.ReadFile(path.Join(config.Path, config.Name))
+	if err != nil {
+		trace.Error().Err(err).Msg("reading ADE file")
+		return ""
+	}
+
+	checksum := sha256.Sum256(data)
+	return fmt.Sprintf("%s@%x", config.Id, checksum[:6])
+}
+
+// loggerCatalog lists the loggables produced by each data packet, for the session selections
+func loggerCatalog(boards []pod_data.Board) logger_handler.IdCatalog {
+	catalog := logger_handler.IdCatalog{
+		Boards:  make(map[string][]uint16),
+		Packets: make(map[uint16][]string),
+	}
+
+	for _, board := range boards {
+		for _, packet := range board.Packets {
+			catalog.Boards[board.Name] = append(catalog.Boards[board.Name], packet.Id)
+
+			ids := []string{fmt.Sprint(packet.Id), columnar_logger.LoggableId(packet.Id)}
+			for _, measurement := range packet.Measurements {
+				ids = append(ids, measurement.GetId())
+			}
+			catalog.Packets[packet.Id] = ids
+		}
+	}
+
+	return catalog
+}
+
+func startPacketUpdateRoutine(vehicleUpdates <-chan vehicle_models.PacketUpdate, dataTransfer *data_transfer.DataTransfer, historyTransfer *history_transfer.HistoryTransfer, loggerHandler *logger_handler.LoggerHandler, procedureRunner *procedure.ProcedureRunner, orderTransfer *order_transfer.OrderTransfer, logColumnar bool) {
 	updateFactory := update_factory.NewFactory()
 
 	for packetUpdate := range vehicleUpdates {
 		update := updateFactory.NewUpdate(packetUpdate)
 		dataTransfer.Update(update)
+		historyTransfer.Update(packetUpdate)
+		procedureRunner.Update(packetUpdate)
+		orderTransfer.UpdateValues(packetUpdate)
+		loggerHandler.UpdateValues(packetUpdate.Values)
 
 		loggerHandler.Log(packet_logger.ToLoggablePacket(packetUpdate))
+		if logColumnar {
+			loggerHandler.Log(columnar_logger.ToLoggablePacketValues(packetUpdate))
+		}
 
 		for id, value := range packetUpdate.Values {
 			loggerHandler.Log(value_logger.ToLoggableValue(id, value, packetUpdate.Metadata.Timestamp))
@@ -360,27 +500,55 @@ func startPacketUpdateRoutine(vehicleUpdates <-chan vehicle_models.PacketUpdate,
 	}
 }
 
-func startMessagesRoutine(vehicleMessages <-chan any, messageTransfer *message_transfer.MessageTransfer, loggerHandler *logger_handler.LoggerHandler) {
+func startMessagesRoutine(vehicleMessages <-chan any, messageTransfer *message_transfer.MessageTransfer, loggerHandler *logger_handler.LoggerHandler, procedureRunner *procedure.ProcedureRunner) {
 	for message := range vehicleMessages {
+		receivedAt := time.Now()
 		messageTransfer.SendMessage(message)
 
 		switch msg := message.(type) {
 		case vehicle_models.InfoMessage:
-			loggerHandler.Log(protection_logger.LoggableInfo(msg))
+			loggerHandler.Log(protection_logger.ToLoggableInfo(msg, receivedAt))
 		case vehicle_models.ProtectionMessage:
-			loggerHandler.Log(protection_logger.LoggableProtection(msg))
+			loggerHandler.Log(protection_logger.ToLoggableProtection(msg, receivedAt))
+			if msg.Kind == "fault" {
+				procedureRunner.NotifyFault(msg.Board)
+				loggerHandler.NotifyFault(msg.Board)
+			}
 		}
 	}
 }
 
+// loggerIds joins the ids logged by each of the loggers
+func loggerIds(loggers ...logger_handler.Logger) common.Set[string] {
+	ids := common.NewSet[string]()
+	for _, logger := range loggers {
+		loggerIds := logger.Ids()
+		loggerIds.ForEach(ids.Add)
+	}
+	return ids
+}
+
+func procedureLoggerIds() common.Set[string] {
+	ids := common.NewSet[string]()
+	ids.Add(procedure.ProcedureLoggableId)
+	return ids
+}
+
 func startOrderRoutine(orderChannel <-chan vehicle_models.Order, vehicle *vehicle.Vehicle, loggerHandler *logger_handler.LoggerHandler) {
 	for ord := range orderChannel {
-		err := vehicle.SendOrder(ord)
+		sendOrder(ord, vehicle, loggerHandler)
+	}
+}
 
-		if err != nil {
-			trace.Error().Any("order", ord).Msg("error sending order")
-		}
+// sendOrder is the path every order takes to the vehicle, so all of them reach the order logger and triggers
+func sendOrder(ord vehicle_models.Order, vehicle *vehicle.Vehicle, loggerHandler *logger_handler.LoggerHandler) error {
+	err := vehicle.SendOrder(ord)
 
-		loggerHandler.Log(order_logger.LoggableOrder(ord))
+	if err != nil {
+		trace.Error().Err(err).Any("order", ord).Msg("error sending order")
 	}
+
+	loggerHandler.Log(order_logger.LoggableOrder(ord))
+	loggerHandler.NotifyOrder(ord.ID)
+	return err
 }
@@ -1,20 +1,26 @@
 package protection_logger
 
 import (
-	"fmt"
+	"time"
 
+	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
 	vehicle_models "github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
 )
 
-type LoggableInfo vehicle_models.InfoMessage
+// LoggableInfo keeps the time the backend received the message, like LoggableProtection
+type LoggableInfo struct {
+	vehicle_models.InfoMessage
+	ReceivedAt time.Time
+}
+
+func ToLoggableInfo(message vehicle_models.InfoMessage, receivedAt time.Time) LoggableInfo {
+	return LoggableInfo{InfoMessage: message, ReceivedAt: receivedAt}
+}
 
 func (info LoggableInfo) Id() string {
 	return "info"
 }
 
 func (info LoggableInfo) Log() []string {
-	date := fmt.Sprintf("%d/%d/%d", info.Timestamp.Day, info.Timestamp.Month, info.Timestamp.Year)
-	time := fmt.Sprintf("%d:%d:%d", info.Timestamp.Hour, info.Timestamp.Minute, info.Timestamp.Second)
-	datetime := fmt.Sprintf("%s %s", date, time)
-	return []string{datetime, "info", info.Board, info.Msg}
+	return []string{logger_handler.FormatTimestamp(info.ReceivedAt), "info", info.Board, "", "", info.Msg, info.Timestamp.String()}
 }
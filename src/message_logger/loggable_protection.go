@@ -2,23 +2,33 @@ package protection_logger
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
 	vehicle_models "github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
 )
 
-type LoggableProtection vehicle_models.ProtectionMessage
+// Header starts with the time the backend received the message, board_timestamp is the clock of the board, without time zone
+var Header = []string{"timestamp", "kind", "board", "name", "protection", "data", "board_timestamp"}
+
+// LoggableProtection keeps the time the backend received the message, the board timestamp has no date precision nor time zone
+type LoggableProtection struct {
+	vehicle_models.ProtectionMessage
+	ReceivedAt time.Time
+}
+
+func ToLoggableProtection(message vehicle_models.ProtectionMessage, receivedAt time.Time) LoggableProtection {
+	return LoggableProtection{ProtectionMessage: message, ReceivedAt: receivedAt}
+}
 
 func (lp LoggableProtection) Id() string {
 	return lp.Kind
 }
 
 func (lp LoggableProtection) Log() []string {
-	date := fmt.Sprintf("%d/%d/%d", lp.Timestamp.Day, lp.Timestamp.Month, lp.Timestamp.Year)
-	time := fmt.Sprintf("%d:%d:%d", lp.Timestamp.Hour, lp.Timestamp.Minute, lp.Timestamp.Second)
-	datetime := fmt.Sprintf("%s %s", date, time)
 	data := getDataString(lp.Protection.Data)
 
-	return []string{datetime, lp.Kind, lp.Board, lp.Name, lp.Protection.Kind, data}
+	return []string{logger_handler.FormatTimestamp(lp.ReceivedAt), lp.Kind, lp.Board, lp.Name, lp.Protection.Kind, data, lp.Timestamp.String()}
 }
 
 func getDataString(data any) string {
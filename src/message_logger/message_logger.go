@@ -11,7 +11,7 @@ func NewMessageLogger(infoId string, warningId string, faultId string, config fi
 	ids.Add(warningId)
 	ids.Add(faultId)
 
-	fileLogger := file_logger.NewFileLogger("orderLogger", ids, config)
+	fileLogger := file_logger.NewFileLogger("orderLogger", ids, Header, config)
 
 	return fileLogger
 }
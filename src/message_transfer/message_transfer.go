@@ -1,6 +1,9 @@
 package message_transfer
 
 import (
+	"sync"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
 	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
 
 	"github.com/HyperloopUPV-H8/Backend-H8/common/observable"
@@ -13,26 +16,58 @@ const (
 	UpdateTopic                = "message/update"
 )
 
+const DEFAULT_HISTORY_SIZE = 100
+
 type MessageTransfer struct {
 	updateTopic       string
-	messageObservable observable.NoReplayObservable[any]
-	trace             zerolog.Logger
+	messageObservable observable.SnapshotObservable[any]
+	// history keeps the last messages for the new subscribers, historyMx is held
+	// while sending and subscribing so each message reaches a subscriber exactly once
+	historyMx *sync.Mutex
+	history   *common.RingBuf[any]
+	trace     zerolog.Logger
 }
 type MessageTransferConfig struct {
 	UpdateTopic string `toml:"update_topic"`
+	HistorySize int    `toml:"history_size"`
 }
 
 func New(config MessageTransferConfig) MessageTransfer {
 	trace.Info().Msg("new message transfer")
+
+	size := config.HistorySize
+	if size <= 0 {
+		size = DEFAULT_HISTORY_SIZE
+	}
+	history := common.NewRingBuf[any](size)
+
 	return MessageTransfer{
-		updateTopic:       config.UpdateTopic,
-		messageObservable: observable.NewNoReplayObservable[any](),
-		// messageObservable: observable.NewWsObservable[any](struct{}{}, func(v any, id string) error { return defaultSendMessage(id, v) }), //FIXME: change struct{}{}
-		trace: trace.With().Str("component", MessageTransferHandlerName).Logger(),
+		updateTopic: config.UpdateTopic,
+		messageObservable: observable.NewSnapshotObservable(func() []any {
+			return recentMessages(&history)
+		}),
+		historyMx: &sync.Mutex{},
+		history:   &history,
+		trace:     trace.With().Str("component", MessageTransferHandlerName).Logger(),
 	}
 }
 
+// recentMessages returns the stored messages from the oldest to the newest, it expects historyMx to be held
+func recentMessages(history *common.RingBuf[any]) []any {
+	messages := make([]any, 0, history.Len())
+	for _, message := range history.Items() {
+		if message != nil {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
 func (messageTransfer *MessageTransfer) SendMessage(message any) error {
+	messageTransfer.historyMx.Lock()
+	defer messageTransfer.historyMx.Unlock()
+
+	messageTransfer.history.Add(message)
 	messageTransfer.messageObservable.Next(message)
 	return nil
 }
@@ -40,6 +75,8 @@ func (messageTransfer *MessageTransfer) SendMessage(message any) error {
 func (messageTransfer *MessageTransfer) UpdateMessage(client wsModels.Client, msg wsModels.Message) {
 	messageTransfer.trace.Info().Str("client", client.Id()).Str("topic", msg.Topic).Msg("got message")
 
+	messageTransfer.historyMx.Lock()
+	defer messageTransfer.historyMx.Unlock()
 	observable.HandleSubscribe[any](&messageTransfer.messageObservable, msg, client)
 }
 
@@ -0,0 +1,19 @@
+package order_logger
+
+import (
+	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
+	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+)
+
+const EmergencyStopId = "emergency"
+
+type LoggableEmergencyStop models.EmergencyStop
+
+func (les LoggableEmergencyStop) Id() string {
+	return EmergencyStopId
+}
+
+func (les LoggableEmergencyStop) Log() []string {
+	fields := map[string]any{"reason": les.Reason, "results": les.Results}
+	return []string{"[EMERGENCY]", logger_handler.FormatTimestamp(les.Timestamp), les.Source, "", "", "", logger_handler.FormatJSON(fields)}
+}
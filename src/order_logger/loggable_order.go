@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
 	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
 )
 
+// Header is shared by every row of the order log, fields holds a JSON object whose shape depends on the kind
+var Header = []string{"kind", "timestamp", "from", "to", "seq_num", "id", "fields"}
+
 type LoggableOrder models.Order
 
 func (lo LoggableOrder) Id() string {
@@ -14,7 +18,7 @@ func (lo LoggableOrder) Id() string {
 }
 
 func (lo LoggableOrder) Log() []string {
-	return []string{"[GUI]", time.Now().String(), "", "", "", fmt.Sprint(lo.ID), fmt.Sprint(lo.Fields)}
+	return []string{"[GUI]", logger_handler.FormatTimestamp(time.Now()), "", "", "", fmt.Sprint(lo.ID), logger_handler.FormatJSON(lo.Fields)}
 }
 
 type LoggableTransmittedOrder models.PacketUpdate
@@ -24,6 +28,10 @@ func (lto LoggableTransmittedOrder) Id() string {
 }
 
 func (lto LoggableTransmittedOrder) Log() []string {
+	values := make(map[string]any, len(lto.Values))
+	for id, value := range lto.Values {
+		values[id] = value.Inner()
+	}
 
-	return []string{"[TRANSMITTED]", fmt.Sprint(lto.Metadata.Timestamp), fmt.Sprint(lto.Metadata.From), fmt.Sprint(lto.Metadata.To), fmt.Sprint(lto.Metadata.SeqNum), fmt.Sprint(lto.Metadata.ID), fmt.Sprint(lto.Values)}
+	return []string{"[TRANSMITTED]", logger_handler.FormatTimestamp(lto.Metadata.Timestamp), lto.Metadata.From, lto.Metadata.To, fmt.Sprint(lto.Metadata.SeqNum), fmt.Sprint(lto.Metadata.ID), logger_handler.FormatJSON(values)}
 }
@@ -0,0 +1,19 @@
+package order_logger
+
+import (
+	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
+	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+)
+
+const StateOrderEventId = "stateOrders"
+
+type LoggableStateOrderEvent models.StateOrderEvent
+
+func (lse LoggableStateOrderEvent) Id() string {
+	return StateOrderEventId
+}
+
+func (lse LoggableStateOrderEvent) Log() []string {
+	fields := map[string]any{"action": lse.Action, "orders": lse.Orders}
+	return []string{"[STATE_ORDERS]", logger_handler.FormatTimestamp(lse.Timestamp), lse.Board, "", "", "", logger_handler.FormatJSON(fields)}
+}
@@ -10,6 +10,8 @@ import (
 
 func NewOrderLogger(boards []pod_data.Board, config file_logger.Config) file_logger.FileLogger {
 	ids := common.NewSet[string]()
+	ids.Add(EmergencyStopId)
+	ids.Add(StateOrderEventId)
 
 	for _, board := range boards {
 		for _, packet := range board.Packets {
@@ -19,7 +21,7 @@ func NewOrderLogger(boards []pod_data.Board, config file_logger.Config) file_log
 		}
 	}
 
-	fileLogger := file_logger.NewFileLogger("orderLogger", ids, config)
+	fileLogger := file_logger.NewFileLogger("orderLogger", ids, Header, config)
 
 	return fileLogger
 }
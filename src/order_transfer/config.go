@@ -0,0 +1,19 @@
+package order_transfer
+
+import "github.com/HyperloopUPV-H8/Backend-H8/condition"
+
+type Config struct {
+	SendTopic   string      `toml:"send_topic"`
+	RejectTopic string      `toml:"reject_topic"`
+	Interlocks  []Interlock `toml:"interlocks"`
+
+	StateOrdersQueryTopic  string `toml:"state_orders_query_topic"`
+	StateOrdersHistorySize int    `toml:"state_orders_history_size"`
+}
+
+// Interlock only allows the order when all its state orders are enabled and all its conditions are met
+type Interlock struct {
+	Order       uint16                `toml:"order"`
+	StateOrders []uint16              `toml:"state_orders"`
+	Conditions  []condition.Condition `toml:"conditions"`
+}
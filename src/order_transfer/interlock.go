@@ -0,0 +1,70 @@
+package order_transfer
+
+import (
+	"fmt"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/condition"
+	vehicle_models "github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+)
+
+type OrderRejection struct {
+	Id     uint16 `json:"id"`
+	Reason string `json:"reason"`
+}
+
+func getInterlocks(interlocks []Interlock) map[uint16][]Interlock {
+	orderInterlocks := make(map[uint16][]Interlock)
+	for _, interlock := range interlocks {
+		orderInterlocks[interlock.Order] = append(orderInterlocks[interlock.Order], interlock)
+	}
+	return orderInterlocks
+}
+
+// CheckInterlocks returns an error describing the first interlock rule that blocks the order
+func (orderTransfer *OrderTransfer) CheckInterlocks(order vehicle_models.Order) error {
+	for _, interlock := range orderTransfer.interlocks[order.ID] {
+		for _, stateOrder := range interlock.StateOrders {
+			if !orderTransfer.IsStateOrderEnabled(stateOrder) {
+				return fmt.Errorf("state order %d is not enabled", stateOrder)
+			}
+		}
+
+		for _, condition := range interlock.Conditions {
+			if err := orderTransfer.checkCondition(condition); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (orderTransfer *OrderTransfer) checkCondition(condition condition.Condition) error {
+	orderTransfer.valuesMx.Lock()
+	value, ok := orderTransfer.values[condition.Measurement]
+	orderTransfer.valuesMx.Unlock()
+
+	if !ok {
+		return fmt.Errorf("measurement %s has not been received", condition.Measurement)
+	}
+
+	met, err := condition.Evaluate(value)
+	if err != nil {
+		return err
+	}
+
+	if !met {
+		return fmt.Errorf("condition %s not met", condition)
+	}
+
+	return nil
+}
+
+func (orderTransfer *OrderTransfer) UpdateValues(update vehicle_models.PacketUpdate) {
+	orderTransfer.valuesMx.Lock()
+	defer orderTransfer.valuesMx.Unlock()
+
+	for id, value := range update.Values {
+		orderTransfer.values[id] = value
+	}
+}
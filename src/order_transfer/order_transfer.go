@@ -2,12 +2,15 @@ package order_transfer
 
 import (
 	"encoding/json"
+	"fmt"
 	"sync"
+	"time"
 
 	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
 
 	"github.com/HyperloopUPV-H8/Backend-H8/common"
 	"github.com/HyperloopUPV-H8/Backend-H8/common/observable"
+	"github.com/HyperloopUPV-H8/Backend-H8/packet"
 	vehicle_models "github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
 	"github.com/rs/zerolog"
 	trace "github.com/rs/zerolog/log"
@@ -24,11 +27,19 @@ type OrderTransfer struct {
 	stateOrdersMx         *sync.Mutex
 	stateOrders           map[string][]uint16
 	stateOrdersObservable observable.ReplayObservable[map[string][]uint16]
+	stateOrdersHistory    []vehicle_models.StateOrderEvent
+	enabledSince          map[string]map[uint16]time.Time
+	historySize           int
+	onStateOrderEvent     func(vehicle_models.StateOrderEvent)
+	valuesMx              *sync.Mutex
+	values                map[string]packet.Value
+	interlocks            map[uint16][]Interlock
 	channel               chan<- vehicle_models.Order
+	config                Config
 	trace                 zerolog.Logger
 }
 
-func New() (OrderTransfer, <-chan vehicle_models.Order) {
+func New(config Config) (OrderTransfer, <-chan vehicle_models.Order) {
 	trace.Info().Msg("new order transfer")
 	channel := make(chan vehicle_models.Order, ORDER_CHAN_BUFFER)
 	stateOrders := make(map[string][]uint16)
@@ -37,6 +48,14 @@ func New() (OrderTransfer, <-chan vehicle_models.Order) {
 		channel:               channel,
 		stateOrders:           stateOrders,
 		stateOrdersObservable: observable.NewReplayObservable(stateOrders),
+		stateOrdersHistory:    make([]vehicle_models.StateOrderEvent, 0),
+		enabledSince:          make(map[string]map[uint16]time.Time),
+		historySize:           getHistorySize(config.StateOrdersHistorySize),
+		onStateOrderEvent:     func(vehicle_models.StateOrderEvent) {},
+		valuesMx:              &sync.Mutex{},
+		values:                make(map[string]packet.Value),
+		interlocks:            getInterlocks(config.Interlocks),
+		config:                config,
 		trace:                 trace.With().Str("component", ORDER_TRASNFER_NAME).Logger(),
 	}, channel
 }
@@ -44,48 +63,96 @@ func New() (OrderTransfer, <-chan vehicle_models.Order) {
 func (orderTransfer *OrderTransfer) UpdateMessage(client wsModels.Client, msg wsModels.Message) {
 	orderTransfer.trace.Info().Str("client", client.Id()).Str("topic", msg.Topic).Msg("got message")
 	switch msg.Topic {
-	case "order/send":
-		orderTransfer.handleOrder(msg.Topic, msg.Payload, client.Id())
+	case orderTransfer.config.SendTopic:
+		orderTransfer.handleOrder(client, msg.Topic, msg.Payload)
 	case "order/stateOrders":
 		orderTransfer.handleSubscription(client, msg)
+	case orderTransfer.config.StateOrdersQueryTopic:
+		orderTransfer.handleQuery(client, msg)
 	}
 }
 
+func getHistorySize(size int) int {
+	if size <= 0 {
+		return DEFAULT_STATE_ORDER_HISTORY_SIZE
+	}
+	return size
+}
+
 func (orderTransfer *OrderTransfer) handleSubscription(client wsModels.Client, msg wsModels.Message) {
 	observable.HandleSubscribe[map[string][]uint16](&orderTransfer.stateOrdersObservable, msg, client)
 }
 
 func (orderTransfer *OrderTransfer) AddStateOrders(stateOrders vehicle_models.StateOrdersMessage) {
 	orderTransfer.stateOrdersMx.Lock()
-	defer orderTransfer.stateOrdersMx.Unlock()
 	orderTransfer.stateOrders[stateOrders.BoardId] = common.Union(orderTransfer.stateOrders[stateOrders.BoardId], stateOrders.Orders...)
+	event := orderTransfer.recordStateOrders(stateOrders.BoardId, vehicle_models.AddStateOrdersAction, stateOrders.Orders)
 	orderTransfer.stateOrdersObservable.Next(orderTransfer.stateOrders)
+	orderTransfer.stateOrdersMx.Unlock()
+
+	orderTransfer.onStateOrderEvent(event)
 }
 
 func (orderTransfer *OrderTransfer) RemoveStateOrders(stateOrders vehicle_models.StateOrdersMessage) {
 	orderTransfer.stateOrdersMx.Lock()
-	defer orderTransfer.stateOrdersMx.Unlock()
 	orderTransfer.stateOrders[stateOrders.BoardId] = common.Subtract(orderTransfer.stateOrders[stateOrders.BoardId], stateOrders.Orders...)
+	event := orderTransfer.recordStateOrders(stateOrders.BoardId, vehicle_models.RemoveStateOrdersAction, stateOrders.Orders)
 	orderTransfer.stateOrdersObservable.Next(orderTransfer.stateOrders)
+	orderTransfer.stateOrdersMx.Unlock()
+
+	orderTransfer.onStateOrderEvent(event)
 }
 
-func (orderTransfer *OrderTransfer) handleOrder(topic string, payload json.RawMessage, source string) {
+func (orderTransfer *OrderTransfer) IsStateOrderEnabled(id uint16) bool {
+	orderTransfer.stateOrdersMx.Lock()
+	defer orderTransfer.stateOrdersMx.Unlock()
+	for _, orders := range orderTransfer.stateOrders {
+		if common.Contains(orders, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (orderTransfer *OrderTransfer) handleOrder(client wsModels.Client, topic string, payload json.RawMessage) {
 	var order vehicle_models.Order
 	if err := json.Unmarshal(payload, &order); err != nil {
 		orderTransfer.trace.Error().Stack().Err(err).Msg("")
 		return
 	}
-	orderTransfer.trace.Info().Str("source", source).Str("topic", topic).Uint16("id", order.ID).Msg("send order")
+
+	if client.IsReadOnly() {
+		orderTransfer.reject(client, order, fmt.Errorf("client role %s can't send orders", client.Role()))
+		return
+	}
+
+	if err := orderTransfer.CheckInterlocks(order); err != nil {
+		orderTransfer.reject(client, order, err)
+		return
+	}
+
+	orderTransfer.trace.Info().Str("source", client.Id()).Str("topic", topic).Uint16("id", order.ID).Msg("send order")
 	orderTransfer.channel <- order
 }
 
+func (orderTransfer *OrderTransfer) reject(client wsModels.Client, order vehicle_models.Order, reason error) {
+	orderTransfer.trace.Warn().Str("source", client.Id()).Uint16("id", order.ID).Err(reason).Msg("order rejected")
+
+	if err := client.WriteMessage(orderTransfer.config.RejectTopic, OrderRejection{Id: order.ID, Reason: reason.Error()}); err != nil {
+		orderTransfer.trace.Error().Err(err).Msg("sending rejection message")
+	}
+}
+
 func (orderTransfer *OrderTransfer) HandlerName() string {
 	return ORDER_TRASNFER_NAME
 }
 
 func (orderTransfer *OrderTransfer) ClearOrders(board string) {
 	orderTransfer.stateOrdersMx.Lock()
-	defer orderTransfer.stateOrdersMx.Unlock()
+	event := orderTransfer.recordStateOrders(board, vehicle_models.ClearStateOrdersAction, orderTransfer.stateOrders[board])
 	orderTransfer.stateOrders[board] = []uint16{}
 	orderTransfer.stateOrdersObservable.Next(orderTransfer.stateOrders)
+	orderTransfer.stateOrdersMx.Unlock()
+
+	orderTransfer.onStateOrderEvent(event)
 }
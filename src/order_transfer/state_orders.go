@@ -0,0 +1,112 @@
+package order_transfer
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	vehicle_models "github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
+)
+
+const DEFAULT_STATE_ORDER_HISTORY_SIZE = 1000
+
+type EnabledStateOrder struct {
+	Id        uint16    `json:"id"`
+	EnabledAt time.Time `json:"enabledAt"`
+}
+
+type StateOrdersQueryRequest struct {
+	Board string `json:"board,omitempty"`
+}
+
+type StateOrdersQueryResponse struct {
+	Enabled map[string][]EnabledStateOrder   `json:"enabled"`
+	History []vehicle_models.StateOrderEvent `json:"history"`
+}
+
+func (orderTransfer *OrderTransfer) SetOnStateOrderEvent(onStateOrderEvent func(vehicle_models.StateOrderEvent)) {
+	orderTransfer.onStateOrderEvent = onStateOrderEvent
+}
+
+// recordStateOrders must be called with stateOrdersMx held, the returned event is passed to onStateOrderEvent after unlocking it
+func (orderTransfer *OrderTransfer) recordStateOrders(board string, action string, orders []uint16) vehicle_models.StateOrderEvent {
+	now := time.Now()
+
+	enabledSince, ok := orderTransfer.enabledSince[board]
+	if !ok {
+		enabledSince = make(map[uint16]time.Time)
+		orderTransfer.enabledSince[board] = enabledSince
+	}
+
+	switch action {
+	case vehicle_models.AddStateOrdersAction:
+		for _, order := range orders {
+			if _, ok := enabledSince[order]; !ok {
+				enabledSince[order] = now
+			}
+		}
+	case vehicle_models.RemoveStateOrdersAction:
+		for _, order := range orders {
+			delete(enabledSince, order)
+		}
+	case vehicle_models.ClearStateOrdersAction:
+		delete(orderTransfer.enabledSince, board)
+	}
+
+	event := vehicle_models.StateOrderEvent{
+		Board:     board,
+		Action:    action,
+		Orders:    orders,
+		Timestamp: now,
+	}
+
+	orderTransfer.stateOrdersHistory = append(orderTransfer.stateOrdersHistory, event)
+	if overflow := len(orderTransfer.stateOrdersHistory) - orderTransfer.historySize; overflow > 0 {
+		orderTransfer.stateOrdersHistory = orderTransfer.stateOrdersHistory[overflow:]
+	}
+
+	return event
+}
+
+func (orderTransfer *OrderTransfer) QueryStateOrders(board string) StateOrdersQueryResponse {
+	orderTransfer.stateOrdersMx.Lock()
+	defer orderTransfer.stateOrdersMx.Unlock()
+
+	response := StateOrdersQueryResponse{
+		Enabled: make(map[string][]EnabledStateOrder),
+		History: make([]vehicle_models.StateOrderEvent, 0),
+	}
+
+	for name, orders := range orderTransfer.stateOrders {
+		if board != "" && name != board {
+			continue
+		}
+
+		response.Enabled[name] = common.Map(orders, func(order uint16) EnabledStateOrder {
+			return EnabledStateOrder{Id: order, EnabledAt: orderTransfer.enabledSince[name][order]}
+		})
+	}
+
+	for _, event := range orderTransfer.stateOrdersHistory {
+		if board == "" || event.Board == board {
+			response.History = append(response.History, event)
+		}
+	}
+
+	return response
+}
+
+func (orderTransfer *OrderTransfer) handleQuery(client wsModels.Client, msg wsModels.Message) {
+	var request StateOrdersQueryRequest
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &request); err != nil {
+			orderTransfer.trace.Error().Err(err).Msg("unmarshal state orders query")
+			return
+		}
+	}
+
+	if err := client.WriteMessage(msg.Topic, orderTransfer.QueryStateOrders(request.Board)); err != nil {
+		orderTransfer.trace.Error().Err(err).Msg("sending state orders query response")
+	}
+}
@@ -3,10 +3,13 @@ package packet_logger
 import (
 	"fmt"
 
+	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
 	"github.com/HyperloopUPV-H8/Backend-H8/packet"
 	vehicle_models "github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
 )
 
+var Header = []string{"timestamp", "from", "to", "id", "hex_value"}
+
 type LoggablePacket struct {
 	Metadata packet.Metadata
 	HexValue []byte
@@ -18,7 +21,7 @@ func (packet LoggablePacket) Id() string {
 
 func (packet LoggablePacket) Log() []string {
 	return []string{
-		packet.Metadata.Timestamp.String(),
+		logger_handler.FormatTimestamp(packet.Metadata.Timestamp),
 		packet.Metadata.From,
 		packet.Metadata.To,
 		fmt.Sprintf("%d", packet.Metadata.ID),
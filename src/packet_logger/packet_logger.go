@@ -11,7 +11,7 @@ import (
 func NewPacketLogger(boards []pod_data.Board, config file_logger.Config) file_logger.FileLogger {
 	ids := getIds(boards)
 
-	return file_logger.NewFileLogger("packetLogger", ids, config)
+	return file_logger.NewFileLogger("packetLogger", ids, Header, config)
 }
 
 func getIds(boards []pod_data.Board) common.Set[string] {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"os/exec"
+	"sync"
 	"time"
 
 	"github.com/HyperloopUPV-H8/Backend-H8/common"
@@ -70,6 +71,7 @@ func newPipe(laddr net.TCPAddr, raddr net.TCPAddr, keepaliveInterval, writeTimeo
 	trace.Info().Any("laddr", laddr).Any("raddr", raddr).Msg("new pipe")
 
 	pipe := &Pipe{
+		connMx: &sync.Mutex{},
 		laddr:  &laddr,
 		raddr:  &raddr,
 		output: outputChan,
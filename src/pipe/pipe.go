@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/HyperloopUPV-H8/Backend-H8/common"
@@ -14,7 +15,9 @@ import (
 const IdSize = 2
 
 type Pipe struct {
-	conn *net.TCPConn
+	// connMx guards conn and isClosed, which are replaced by the reconnect goroutine
+	connMx *sync.Mutex
+	conn   *net.TCPConn
 
 	laddr *net.TCPAddr
 	raddr *net.TCPAddr
@@ -39,7 +42,7 @@ func (pipe *Pipe) connect() {
 		LocalAddr: pipe.laddr,
 	}
 
-	for pipe.isClosed {
+	for pipe.closed() {
 		pipe.trace.Trace().Msg("dial")
 
 		if pipe.writeTiemout != nil {
@@ -71,10 +74,14 @@ func (pipe *Pipe) keepalive(interval *time.Duration) {
 
 func (pipe *Pipe) open(conn *net.TCPConn) {
 	pipe.trace.Debug().Msg("open")
+	conn.SetNoDelay(true)
+
+	pipe.connMx.Lock()
 	pipe.conn = conn
 	pipe.isClosed = false
-	pipe.conn.SetNoDelay(true)
-	pipe.onConnectionChange(!pipe.isClosed)
+	pipe.connMx.Unlock()
+
+	pipe.onConnectionChange(true)
 	if pipe.keepaliveInterval != nil {
 		go pipe.keepalive(pipe.keepaliveInterval)
 	}
@@ -82,9 +89,10 @@ func (pipe *Pipe) open(conn *net.TCPConn) {
 
 func (pipe *Pipe) listen() {
 	pipe.trace.Info().Msg("start listening")
+	conn := pipe.getConn()
 	for {
 		idBuf := make([]byte, IdSize)
-		_, err := pipe.conn.Read(idBuf)
+		_, err := conn.Read(idBuf)
 
 		if err != nil {
 			pipe.trace.Error().Stack().Err(err).Msg("")
@@ -105,7 +113,7 @@ func (pipe *Pipe) listen() {
 			continue
 		}
 
-		payloadBuf, err := reader.ReadFrom(pipe.conn)
+		payloadBuf, err := reader.ReadFrom(conn)
 
 		if err != nil {
 			pipe.trace.Error().Stack().Err(err).Msg("")
@@ -139,34 +147,47 @@ func (pipe *Pipe) getRaw(payload []byte) packet.Packet {
 	}
 }
 
-func (pipe *Pipe) SendFault(from string, payload []byte) {
+func (pipe *Pipe) SendFault(from string, faultId uint16, payload []byte) {
 	if from == pipe.raddr.String() {
 		return
 	}
 
-	pipe.Write(append([]byte{0x02, 0x00}, payload...))
+	// faults are read with their length prefix removed, so it has to be added back
+	header := make([]byte, IdSize+2)
+	binary.LittleEndian.PutUint16(header[:IdSize], faultId)
+	binary.LittleEndian.PutUint16(header[IdSize:], uint16(len(payload)))
+	pipe.Write(append(header, payload...))
 }
 
 func (pipe *Pipe) Write(data []byte) (int, error) {
-	if pipe == nil || pipe.conn == nil {
-		err := errors.New("pipe is nil")
+	if pipe == nil {
+		return 0, errors.New("pipe is nil")
+	}
+
+	conn := pipe.getConn()
+	if conn == nil {
+		err := errors.New("pipe is not connected")
 		pipe.trace.Error().Stack().Err(err).Msg("")
 		return 0, err
 	}
 
 	pipe.trace.Trace().Msg("write")
 	if pipe.writeTiemout != nil {
-		pipe.conn.SetWriteDeadline(time.Now().Add(*pipe.writeTiemout))
+		conn.SetWriteDeadline(time.Now().Add(*pipe.writeTiemout))
 	}
-	return pipe.conn.Write(data)
+	return conn.Write(data)
 }
 
 func (pipe *Pipe) Close(reconnect bool) error {
 	pipe.trace.Warn().Bool("reconnect", reconnect).Msg("close")
 
+	pipe.connMx.Lock()
 	err := pipe.conn.Close()
 	pipe.isClosed = err == nil
-	pipe.onConnectionChange(!pipe.isClosed)
+	isClosed := pipe.isClosed
+	pipe.connMx.Unlock()
+
+	pipe.onConnectionChange(!isClosed)
 
 	if reconnect {
 		go pipe.connect()
@@ -174,6 +195,28 @@ func (pipe *Pipe) Close(reconnect bool) error {
 	return err
 }
 
+func (pipe *Pipe) IsConnected() bool {
+	if pipe == nil {
+		return false
+	}
+
+	pipe.connMx.Lock()
+	defer pipe.connMx.Unlock()
+	return pipe.conn != nil && !pipe.isClosed
+}
+
+func (pipe *Pipe) getConn() *net.TCPConn {
+	pipe.connMx.Lock()
+	defer pipe.connMx.Unlock()
+	return pipe.conn
+}
+
+func (pipe *Pipe) closed() bool {
+	pipe.connMx.Lock()
+	defer pipe.connMx.Unlock()
+	return pipe.isClosed
+}
+
 func (pipe *Pipe) Laddr() string {
 	return pipe.laddr.String()
 }
@@ -0,0 +1,23 @@
+package procedure
+
+type Config struct {
+	ScriptsPath    string `toml:"scripts_path"`
+	PollInterval   string `toml:"poll_interval"`
+	DefaultTimeout string `toml:"default_timeout"`
+	Topics         Topics `toml:"topics"`
+}
+
+type Topics struct {
+	Run      string `toml:"run"`
+	Abort    string `toml:"abort"`
+	List     string `toml:"list"`
+	Progress string `toml:"progress"`
+}
+
+const (
+	ProcedureHandlerName   = "procedureRunner"
+	ProcedureComponentName = "procedureRunner"
+	ProcedureLoggableId    = "procedure"
+	FAULT_CHAN_BUF         = 1
+	ABORT_CHAN_BUF         = 1
+)
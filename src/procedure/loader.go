@@ -0,0 +1,104 @@
+package procedure
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func LoadProcedure(path string) (Procedure, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Procedure{}, err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return decodeProcedure(raw)
+	case ".yaml", ".yml":
+		return decodeYAMLProcedure(raw)
+	default:
+		return Procedure{}, fmt.Errorf("unsupported procedure extension %s", ext)
+	}
+}
+
+// decodeYAMLProcedure converts the yaml document to json first so both formats share the json field names
+func decodeYAMLProcedure(raw []byte) (Procedure, error) {
+	var document any
+	if err := yaml.Unmarshal(raw, &document); err != nil {
+		return Procedure{}, err
+	}
+
+	jsonRaw, err := json.Marshal(document)
+	if err != nil {
+		return Procedure{}, err
+	}
+
+	return decodeProcedure(jsonRaw)
+}
+
+func decodeProcedure(raw []byte) (Procedure, error) {
+	var procedure Procedure
+	if err := json.Unmarshal(raw, &procedure); err != nil {
+		return Procedure{}, err
+	}
+
+	return procedure, validateProcedure(procedure)
+}
+
+func validateProcedure(procedure Procedure) error {
+	for i, step := range procedure.Steps {
+		var err error
+		switch step.Kind {
+		case OrderStep:
+			if step.Order == nil {
+				err = fmt.Errorf("missing order")
+			}
+		case WaitStep, AssertStep:
+			if step.Condition == nil {
+				err = fmt.Errorf("missing condition")
+			}
+		case StateOrderStep:
+			if step.StateOrder == nil {
+				err = fmt.Errorf("missing state order")
+			}
+		case DelayStep:
+			if step.Duration == "" {
+				err = fmt.Errorf("missing duration")
+			}
+		default:
+			err = fmt.Errorf("unknown kind %q", step.Kind)
+		}
+
+		if err != nil {
+			return fmt.Errorf("procedure %s step %d: %w", procedure.Name, i, err)
+		}
+	}
+
+	return nil
+}
+
+func ListProcedures(path string) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".json", ".yaml", ".yml":
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
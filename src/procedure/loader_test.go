@@ -0,0 +1,68 @@
+package procedure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProcedure(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		steps   int
+		wantErr bool
+	}{
+		{"yaml", "p.yaml", "name: p\nsteps:\n  - kind: order\n    order: { id: 1, fields: {} }\n  - kind: delay\n    duration: 1s\n", 2, false},
+		{"json", "p.json", `{"name": "p", "steps": [{"kind": "assert", "condition": {"measurement": "m", "comparator": "==", "value": true}}]}`, 1, false},
+		{"order without order", "p.yaml", "steps:\n  - kind: order\n", 0, true},
+		{"wait without condition", "p.yaml", "steps:\n  - kind: wait\n", 0, true},
+		{"delay without duration", "p.json", `{"steps": [{"kind": "delay"}]}`, 0, true},
+		{"unknown kind", "p.json", `{"steps": [{"kind": "jump"}]}`, 0, true},
+		{"unsupported extension", "p.txt", "steps: []", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.file)
+			if err := os.WriteFile(path, []byte(test.content), 0o644); err != nil {
+				t.Fatalf("writing procedure: %s", err)
+			}
+
+			procedure, err := LoadProcedure(path)
+
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("loading procedure: %s", err)
+			}
+
+			if len(procedure.Steps) != test.steps {
+				t.Fatalf("expected %d steps, got %d", test.steps, len(procedure.Steps))
+			}
+		})
+	}
+
+	t.Run("shipped procedures are valid", func(t *testing.T) {
+		names, err := ListProcedures("../procedures")
+		if err != nil {
+			t.Fatalf("listing procedures: %s", err)
+		}
+
+		if len(names) == 0 {
+			t.Fatalf("expected procedures")
+		}
+
+		for _, name := range names {
+			if _, err := LoadProcedure(filepath.Join("../procedures", name)); err != nil {
+				t.Fatalf("loading %s: %s", name, err)
+			}
+		}
+	})
+}
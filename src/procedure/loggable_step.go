@@ -0,0 +1,30 @@
+package procedure
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
+)
+
+var LoggableStepHeader = []string{"timestamp", "procedure", "step", "kind", "state", "message"}
+
+type LoggableStep struct {
+	Progress  Progress
+	Timestamp time.Time
+}
+
+func (ls LoggableStep) Id() string {
+	return ProcedureLoggableId
+}
+
+func (ls LoggableStep) Log() []string {
+	return []string{
+		logger_handler.FormatTimestamp(ls.Timestamp),
+		ls.Progress.Procedure,
+		fmt.Sprint(ls.Progress.Step),
+		ls.Progress.Kind,
+		ls.Progress.State,
+		ls.Progress.Message,
+	}
+}
@@ -0,0 +1,57 @@
+package procedure
+
+import (
+	"github.com/HyperloopUPV-H8/Backend-H8/condition"
+	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+)
+
+const (
+	OrderStep      = "order"
+	WaitStep       = "wait"
+	StateOrderStep = "state_order"
+	DelayStep      = "delay"
+	AssertStep     = "assert"
+)
+
+const (
+	RunningState  = "running"
+	DoneState     = "done"
+	FailedState   = "failed"
+	AbortedState  = "aborted"
+	RejectedState = "rejected"
+)
+
+type Procedure struct {
+	Name         string `json:"name"`
+	AbortOnFault bool   `json:"abort_on_fault"`
+	Steps        []Step `json:"steps"`
+}
+
+type Step struct {
+	Kind        string               `json:"kind"`
+	Description string               `json:"description,omitempty"`
+	Order       *models.Order        `json:"order,omitempty"`
+	Condition   *condition.Condition `json:"condition,omitempty"`
+	StateOrder  *StateOrderCondition `json:"state_order,omitempty"`
+	Duration    string               `json:"duration,omitempty"`
+	Timeout     string               `json:"timeout,omitempty"`
+}
+
+type StateOrderCondition struct {
+	Id      uint16 `json:"id"`
+	Enabled bool   `json:"enabled"`
+}
+
+type runRequest struct {
+	Name string `json:"name"`
+}
+
+type Progress struct {
+	Procedure   string `json:"procedure"`
+	Step        int    `json:"step"`
+	Total       int    `json:"total"`
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+	State       string `json:"state"`
+	Message     string `json:"message,omitempty"`
+}
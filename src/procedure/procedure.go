@@ -0,0 +1,191 @@
+package procedure
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common/observable"
+	"github.com/HyperloopUPV-H8/Backend-H8/packet"
+	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
+	"github.com/rs/zerolog"
+	trace "github.com/rs/zerolog/log"
+)
+
+type ProcedureRunner struct {
+	valuesMx *sync.Mutex
+	values   map[string]packet.Value
+
+	isRunning *atomic.Bool
+	abortChan chan struct{}
+	faultChan chan string
+
+	progressObservable observable.ReplayObservable[Progress]
+
+	sendOrder           func(models.Order) error
+	isStateOrderEnabled func(uint16) bool
+	onLog               func(LoggableStep)
+
+	pollInterval   time.Duration
+	defaultTimeout time.Duration
+
+	config Config
+	trace  zerolog.Logger
+}
+
+func New(config Config) ProcedureRunner {
+	trace := trace.With().Str("component", ProcedureComponentName).Logger()
+	trace.Info().Msg("new procedure runner")
+
+	pollInterval, err := time.ParseDuration(config.PollInterval)
+	if err != nil {
+		trace.Fatal().Err(err).Str("pollInterval", config.PollInterval).Msg("error parsing poll interval")
+	}
+
+	defaultTimeout, err := time.ParseDuration(config.DefaultTimeout)
+	if err != nil {
+		trace.Fatal().Err(err).Str("defaultTimeout", config.DefaultTimeout).Msg("error parsing default timeout")
+	}
+
+	return ProcedureRunner{
+		valuesMx: &sync.Mutex{},
+		values:   make(map[string]packet.Value),
+
+		isRunning: &atomic.Bool{},
+		abortChan: make(chan struct{}, ABORT_CHAN_BUF),
+		faultChan: make(chan string, FAULT_CHAN_BUF),
+
+		progressObservable: observable.NewReplayObservable(Progress{}),
+
+		sendOrder:           func(models.Order) error { return errors.New("send order not configured") },
+		isStateOrderEnabled: func(uint16) bool { return false },
+		onLog:               func(LoggableStep) {},
+
+		pollInterval:   pollInterval,
+		defaultTimeout: defaultTimeout,
+
+		config: config,
+		trace:  trace,
+	}
+}
+
+func (runner *ProcedureRunner) SetSendOrder(sendOrder func(models.Order) error) {
+	runner.sendOrder = sendOrder
+}
+
+func (runner *ProcedureRunner) SetStateOrderCheck(isStateOrderEnabled func(uint16) bool) {
+	runner.isStateOrderEnabled = isStateOrderEnabled
+}
+
+func (runner *ProcedureRunner) SetOnLog(onLog func(LoggableStep)) {
+	runner.onLog = onLog
+}
+
+func (runner *ProcedureRunner) HandlerName() string {
+	return ProcedureHandlerName
+}
+
+func (runner *ProcedureRunner) UpdateMessage(client wsModels.Client, msg wsModels.Message) {
+	runner.trace.Info().Str("client", client.Id()).Str("topic", msg.Topic).Msg("got message")
+	if client.IsReadOnly() && (msg.Topic == runner.config.Topics.Run || msg.Topic == runner.config.Topics.Abort) {
+		runner.trace.Warn().Str("client", client.Id()).Str("topic", msg.Topic).Msg("read only client tried to control procedures")
+		return
+	}
+
+	switch msg.Topic {
+	case runner.config.Topics.Run:
+		runner.handleRun(client, msg.Payload)
+	case runner.config.Topics.Abort:
+		runner.Abort()
+	case runner.config.Topics.List:
+		runner.handleList(client)
+	case runner.config.Topics.Progress:
+		observable.HandleSubscribe[Progress](&runner.progressObservable, msg, client)
+	}
+}
+
+// Update stores the latest values so wait and assert steps can evaluate them
+func (runner *ProcedureRunner) Update(update models.PacketUpdate) {
+	runner.valuesMx.Lock()
+	defer runner.valuesMx.Unlock()
+
+	for id, value := range update.Values {
+		runner.values[id] = value
+	}
+}
+
+func (runner *ProcedureRunner) NotifyFault(board string) {
+	if !runner.isRunning.Load() {
+		return
+	}
+
+	select {
+	case runner.faultChan <- board:
+	default:
+	}
+}
+
+func (runner *ProcedureRunner) Abort() {
+	if !runner.isRunning.Load() {
+		return
+	}
+
+	select {
+	case runner.abortChan <- struct{}{}:
+	default:
+	}
+}
+
+func (runner *ProcedureRunner) handleRun(client wsModels.Client, payload json.RawMessage) {
+	var request runRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		runner.trace.Error().Err(err).Msg("unmarshal run request")
+		return
+	}
+
+	procedure, err := LoadProcedure(filepath.Join(runner.config.ScriptsPath, filepath.Base(request.Name)))
+	if err != nil {
+		runner.trace.Error().Err(err).Str("name", request.Name).Msg("loading procedure")
+		runner.reject(client, request.Name, err.Error())
+		return
+	}
+
+	if procedure.Name == "" {
+		procedure.Name = request.Name
+	}
+
+	if !runner.isRunning.CompareAndSwap(false, true) {
+		runner.trace.Warn().Str("name", request.Name).Msg("procedure already running")
+		runner.reject(client, request.Name, "another procedure is running")
+		return
+	}
+
+	go runner.run(procedure)
+}
+
+func (runner *ProcedureRunner) handleList(client wsModels.Client) {
+	names, err := ListProcedures(runner.config.ScriptsPath)
+	if err != nil {
+		runner.trace.Error().Err(err).Msg("listing procedures")
+		names = []string{}
+	}
+
+	if err := client.WriteMessage(runner.config.Topics.List, names); err != nil {
+		runner.trace.Error().Err(err).Msg("sending procedure list")
+	}
+}
+
+func (runner *ProcedureRunner) reject(client wsModels.Client, name string, reason string) {
+	if err := client.WriteMessage(runner.config.Topics.Progress, Progress{Procedure: name, State: RejectedState, Message: reason}); err != nil {
+		runner.trace.Error().Err(err).Msg("sending reject message")
+	}
+}
+
+func (runner *ProcedureRunner) notify(progress Progress) {
+	runner.progressObservable.Next(progress)
+	runner.onLog(LoggableStep{Progress: progress, Timestamp: time.Now()})
+}
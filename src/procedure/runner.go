@@ -0,0 +1,195 @@
+package procedure
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/condition"
+)
+
+var (
+	ErrTimeout = errors.New("timeout")
+	ErrAborted = errors.New("aborted by operator")
+)
+
+type faultError struct {
+	board string
+}
+
+func (err faultError) Error() string {
+	return fmt.Sprintf("fault received from %s", err.board)
+}
+
+func (runner *ProcedureRunner) run(procedure Procedure) {
+	defer runner.isRunning.Store(false)
+	runner.drain()
+
+	runner.trace.Info().Str("procedure", procedure.Name).Int("steps", len(procedure.Steps)).Msg("running procedure")
+
+	for i, step := range procedure.Steps {
+		progress := Progress{
+			Procedure:   procedure.Name,
+			Step:        i,
+			Total:       len(procedure.Steps),
+			Kind:        step.Kind,
+			Description: step.Description,
+			State:       RunningState,
+		}
+		runner.notify(progress)
+
+		err := runner.runStep(step, procedure.AbortOnFault)
+		if err != nil {
+			runner.trace.Warn().Err(err).Str("procedure", procedure.Name).Int("step", i).Msg("procedure stopped")
+
+			progress.State = FailedState
+			if errors.Is(err, ErrAborted) || errors.As(err, &faultError{}) {
+				progress.State = AbortedState
+			}
+			progress.Message = err.Error()
+			runner.notify(progress)
+			return
+		}
+	}
+
+	runner.trace.Info().Str("procedure", procedure.Name).Msg("procedure done")
+	runner.notify(Progress{
+		Procedure: procedure.Name,
+		Step:      len(procedure.Steps),
+		Total:     len(procedure.Steps),
+		State:     DoneState,
+	})
+}
+
+// drain discards aborts and faults received before the procedure started
+func (runner *ProcedureRunner) drain() {
+	for {
+		select {
+		case <-runner.abortChan:
+		case <-runner.faultChan:
+		default:
+			return
+		}
+	}
+}
+
+func (runner *ProcedureRunner) runStep(step Step, abortOnFault bool) error {
+	switch step.Kind {
+	case OrderStep:
+		if err := runner.checkInterrupt(abortOnFault); err != nil {
+			return err
+		}
+		return runner.sendOrder(*step.Order)
+	case WaitStep:
+		timeout, err := runner.getTimeout(step)
+		if err != nil {
+			return err
+		}
+		return runner.waitFor(func() (bool, error) { return runner.evaluate(*step.Condition) }, timeout, abortOnFault)
+	case StateOrderStep:
+		timeout, err := runner.getTimeout(step)
+		if err != nil {
+			return err
+		}
+		return runner.waitFor(func() (bool, error) {
+			return runner.isStateOrderEnabled(step.StateOrder.Id) == step.StateOrder.Enabled, nil
+		}, timeout, abortOnFault)
+	case DelayStep:
+		duration, err := time.ParseDuration(step.Duration)
+		if err != nil {
+			return err
+		}
+		return runner.sleep(duration, abortOnFault)
+	case AssertStep:
+		if err := runner.checkInterrupt(abortOnFault); err != nil {
+			return err
+		}
+		ok, err := runner.evaluate(*step.Condition)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("assertion failed: %s", step.Condition)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown step kind %s", step.Kind)
+	}
+}
+
+func (runner *ProcedureRunner) getTimeout(step Step) (time.Duration, error) {
+	if step.Timeout == "" {
+		return runner.defaultTimeout, nil
+	}
+
+	return time.ParseDuration(step.Timeout)
+}
+
+func (runner *ProcedureRunner) evaluate(condition condition.Condition) (bool, error) {
+	runner.valuesMx.Lock()
+	value, ok := runner.values[condition.Measurement]
+	runner.valuesMx.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	return condition.Evaluate(value)
+}
+
+func (runner *ProcedureRunner) waitFor(predicate func() (bool, error), timeout time.Duration, abortOnFault bool) error {
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(runner.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := predicate()
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-deadline:
+			return ErrTimeout
+		case <-runner.abortChan:
+			return ErrAborted
+		case board := <-runner.faults(abortOnFault):
+			return faultError{board}
+		case <-ticker.C:
+		}
+	}
+}
+
+func (runner *ProcedureRunner) sleep(duration time.Duration, abortOnFault bool) error {
+	select {
+	case <-time.After(duration):
+		return nil
+	case <-runner.abortChan:
+		return ErrAborted
+	case board := <-runner.faults(abortOnFault):
+		return faultError{board}
+	}
+}
+
+func (runner *ProcedureRunner) checkInterrupt(abortOnFault bool) error {
+	select {
+	case <-runner.abortChan:
+		return ErrAborted
+	case board := <-runner.faults(abortOnFault):
+		return faultError{board}
+	default:
+		return nil
+	}
+}
+
+// faults returns a nil channel when faults should not abort the procedure, so selecting on it never fires
+func (runner *ProcedureRunner) faults(abortOnFault bool) <-chan string {
+	if !abortOnFault {
+		return nil
+	}
+	return runner.faultChan
+}
@@ -0,0 +1,68 @@
+package procedure
+
+import (
+	"testing"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/condition"
+	"github.com/HyperloopUPV-H8/Backend-H8/packet"
+	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+)
+
+func TestRunner(t *testing.T) {
+	order := Step{Kind: OrderStep, Order: &models.Order{ID: 1200}}
+	pressure := condition.Condition{Measurement: "brake_pressure", Comparator: ">=", Value: 5.0}
+
+	tests := []struct {
+		name   string
+		steps  []Step
+		values map[string]packet.Value
+		fault  bool
+		orders int
+		want   string
+	}{
+		{"orders are sent", []Step{order, order}, nil, false, 2, DoneState},
+		{"wait passes", []Step{{Kind: WaitStep, Condition: &pressure}, order}, map[string]packet.Value{"brake_pressure": packet.Numeric(6)}, false, 1, DoneState},
+		{"wait times out", []Step{{Kind: WaitStep, Condition: &pressure, Timeout: "10ms"}, order}, nil, false, 0, FailedState},
+		{"assert fails", []Step{{Kind: AssertStep, Condition: &pressure}}, map[string]packet.Value{"brake_pressure": packet.Numeric(1)}, false, 0, FailedState},
+		{"fault aborts", []Step{{Kind: DelayStep, Duration: "1s"}, order}, nil, true, 0, AbortedState},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runner := New(Config{PollInterval: "1ms", DefaultTimeout: "100ms"})
+
+			orders := 0
+			runner.SetSendOrder(func(models.Order) error {
+				orders++
+				return nil
+			})
+
+			states := make(chan string, len(test.steps)+2)
+			runner.SetOnLog(func(step LoggableStep) {
+				states <- step.Progress.State
+			})
+
+			runner.Update(models.PacketUpdate{Values: test.values})
+			runner.isRunning.Store(true)
+			go runner.run(Procedure{Name: test.name, AbortOnFault: true, Steps: test.steps})
+
+			// the first progress is sent after the faults received before the run are drained
+			state := <-states
+			if test.fault {
+				runner.NotifyFault("BCU")
+			}
+
+			for state == RunningState {
+				state = <-states
+			}
+
+			if state != test.want {
+				t.Fatalf("expected %s, got %s", test.want, state)
+			}
+
+			if orders != test.orders {
+				t.Fatalf("expected %d orders, got %d", test.orders, orders)
+			}
+		})
+	}
+}
@@ -1,5 +1,12 @@
 package server
 
+import (
+	"fmt"
+	"net/http"
+
+	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
+)
+
 type Config map[string]ServerConfig
 
 type ServerConfig struct {
@@ -7,6 +14,25 @@ type ServerConfig struct {
 	MaxConnections *int32         `toml:"client_limit,omitempty"`
 	Endpoints      EndpointConfig `toml:"endpoints"`
 	StaticPath     string         `toml:"static" default:"./static"`
+	// Role is given to every websocket client of this server, only "operator" clients can send commands
+	Role string `toml:"role" default:"operator"`
+	// Compression negotiates permessage-deflate with the websocket clients that support it
+	Compression bool `toml:"compression,omitempty"`
+}
+
+// Validate rejects the servers with an unknown role, an empty role is an operator
+func (config Config) Validate() error {
+	for name, server := range config {
+		if server.Role != "" && !wsModels.IsValidRole(server.Role) {
+			return fmt.Errorf("server %s has unknown role %q", name, server.Role)
+		}
+	}
+	return nil
+}
+
+// IsReadOnly reports whether the server only serves data, it is true for any role other than operator
+func (config ServerConfig) IsReadOnly() bool {
+	return config.Role != "" && config.Role != wsModels.OperatorRole
 }
 
 type EndpointConfig struct {
@@ -21,4 +47,8 @@ type EndpointData struct {
 	PodData           any
 	OrderData         any
 	ProgramableBoards any
+	// Handlers are served under /backend, read only servers only accept GET and HEAD requests on them
+	Handlers map[string]http.Handler
+	// OperatorHandlers are served under /backend only by operator servers, as any request to them commands the vehicle
+	OperatorHandlers map[string]http.Handler
 }
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"sync/atomic"
 
+	wsModels "github.com/HyperloopUPV-H8/Backend-H8/ws_handle/models"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 )
@@ -25,6 +26,16 @@ func NoCacheMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+func ReadOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "read only server", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func NewWebServer(name string, connectionHandle ConnectionHandler, staticData EndpointData, config ServerConfig) (*WebServer, error) {
 	server := &WebServer{
 		name:        name,
@@ -53,8 +64,20 @@ func NewWebServer(name string, connectionHandle ConnectionHandler, staticData En
 		return nil, err
 	}
 
+	for path, handler := range staticData.Handlers {
+		server.serveHandler("/backend"+path, handler, headers)
+	}
+
+	if !config.IsReadOnly() {
+		for path, handler := range staticData.OperatorHandlers {
+			server.serveHandler("/backend"+path, handler, headers)
+		}
+	}
+
 	upgrader := &websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool { return true },
+		CheckOrigin:       func(r *http.Request) bool { return true },
+		Subprotocols:      wsModels.Subprotocols,
+		EnableCompression: config.Compression,
 	}
 	server.serveWebsocket(config.Endpoints.Connections, upgrader, headers)
 	server.serveFiles(config.Endpoints.Files, config.StaticPath)
@@ -85,6 +108,21 @@ func (server *WebServer) serveJSON(path string, data any, headers map[string]str
 	return nil
 }
 
+func (server *WebServer) serveHandler(path string, handler http.Handler, headers map[string]string) {
+	if server.config.IsReadOnly() {
+		handler = ReadOnlyMiddleware(handler)
+	}
+
+	withHeaders := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for key, value := range headers {
+			w.Header().Set(key, value)
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	server.router.Handle(path, NoCacheMiddleware(withHeaders))
+}
+
 func (server *WebServer) serveFiles(path string, staticPath string) {
 	server.router.PathPrefix(path).Handler(NoCacheMiddleware(http.FileServer(http.Dir(staticPath))))
 }
@@ -8,7 +8,7 @@ import (
 )
 
 type ConnectionHandler interface {
-	Add(*websocket.Conn) error
+	Add(conn *websocket.Conn, role string) error
 }
 
 func (server *WebServer) serveWebsocket(path string, upgrader *websocket.Upgrader, headers map[string]string) {
@@ -35,7 +35,7 @@ func (server *WebServer) serveWebsocket(path string, upgrader *websocket.Upgrade
 			return nil
 		})
 
-		err = server.connHandler.Add(conn)
+		err = server.connHandler.Add(conn, server.config.Role)
 		if err != nil {
 			return
 		}
@@ -0,0 +1,84 @@
+package sqlite_logger
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+)
+
+// Handlers returns the query endpoint for the sessions stored under basePath.
+// GET endpoint?measurements=a,b&sessions=x,y&from=RFC3339&to=RFC3339&points=500
+func (sl *SQLiteLogger) Handlers(endpoint string, basePath string) map[string]http.Handler {
+	return map[string]http.Handler{
+		endpoint: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sl.handleQuery(w, r, basePath)
+		}),
+	}
+}
+
+func (sl *SQLiteLogger) handleQuery(w http.ResponseWriter, r *http.Request, basePath string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	request, err := parseQueryRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	series, err := sl.Query(basePath, request)
+	if err != nil {
+		if err != ErrNoMeasurements {
+			sl.trace.Error().Err(err).Msg("querying sessions")
+		}
+		common.WriteError(w, err, []common.ErrorStatus{{Err: ErrNoMeasurements, Status: http.StatusBadRequest}})
+		return
+	}
+
+	common.WriteJSON(w, http.StatusOK, series)
+}
+
+func parseQueryRequest(r *http.Request) (QueryRequest, error) {
+	query := r.URL.Query()
+	request := QueryRequest{
+		Sessions:     splitList(query["sessions"]),
+		Measurements: splitList(query["measurements"]),
+	}
+
+	var err error
+	if from := query.Get("from"); from != "" {
+		if request.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return QueryRequest{}, err
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if request.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return QueryRequest{}, err
+		}
+	}
+	if points := query.Get("points"); points != "" {
+		if request.Points, err = strconv.Atoi(points); err != nil {
+			return QueryRequest{}, err
+		}
+	}
+
+	return request, nil
+}
+
+// splitList accepts both repeated parameters and comma separated values
+func splitList(values []string) []string {
+	items := make([]string, 0)
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
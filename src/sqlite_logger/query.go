@@ -0,0 +1,178 @@
+package sqlite_logger
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
+)
+
+const (
+	DEFAULT_POINTS = 500
+	MAX_POINTS     = 10000
+)
+
+var ErrNoMeasurements = errors.New("no measurements requested")
+
+// QueryRequest asks for the series of the measurements in every session overlapping [From, To].
+// Empty sessions query all of them and zero times leave the range open
+type QueryRequest struct {
+	Sessions     []string  `json:"sessions,omitempty"`
+	Measurements []string  `json:"measurements"`
+	From         time.Time `json:"from,omitempty"`
+	To           time.Time `json:"to,omitempty"`
+	// Points is the maximum number of buckets of each series
+	Points int `json:"points,omitempty"`
+}
+
+// Series holds the buckets of one measurement in one session, Min, Max and Mean summarize the whole range
+type Series struct {
+	Session     string          `json:"session"`
+	Measurement string          `json:"measurement"`
+	Min         float64         `json:"min"`
+	Max         float64         `json:"max"`
+	Mean        float64         `json:"mean"`
+	Count       int64           `json:"count"`
+	Buckets     []common.Bucket `json:"buckets"`
+}
+
+// Query downsamples the requested measurements of the sessions under basePath that have a database
+func (sl *SQLiteLogger) Query(basePath string, request QueryRequest) ([]Series, error) {
+	if len(request.Measurements) == 0 {
+		return nil, ErrNoMeasurements
+	}
+
+	points := request.Points
+	if points <= 0 {
+		points = DEFAULT_POINTS
+	} else if points > MAX_POINTS {
+		points = MAX_POINTS
+	}
+
+	sessions, err := logger_handler.ListSessions(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	requested := common.NewSet[string]()
+	for _, name := range request.Sessions {
+		requested.Add(name)
+	}
+
+	series := make([]Series, 0)
+	for _, session := range sessions {
+		if len(request.Sessions) > 0 && !requested.Has(session.Name) {
+			continue
+		}
+
+		from, to, ok := sessionRange(session, request.From, request.To)
+		if !ok {
+			continue
+		}
+
+		path := filepath.Join(basePath, session.Name, sl.fileName)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		sessionSeries, err := querySession(path, session.Name, request.Measurements, from, to, points)
+		if err != nil {
+			sl.trace.Error().Err(err).Str("session", session.Name).Msg("error querying session")
+			continue
+		}
+		series = append(series, sessionSeries...)
+	}
+
+	return series, nil
+}
+
+// sessionRange clamps the requested range to the session, reporting false when they don't overlap
+func sessionRange(session logger_handler.Session, from time.Time, to time.Time) (time.Time, time.Time, bool) {
+	end := time.Now()
+	if session.EndTime != nil {
+		end = *session.EndTime
+	}
+
+	if from.IsZero() || from.Before(session.StartTime) {
+		from = session.StartTime
+	}
+	if to.IsZero() || to.After(end) {
+		to = end
+	}
+
+	return from, to, to.After(from)
+}
+
+func querySession(path string, session string, measurements []string, from time.Time, to time.Time, points int) ([]Series, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=ro&_pragma=busy_timeout(5000)", path))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	width := to.Sub(from).Nanoseconds() / int64(points)
+	if width <= 0 {
+		width = 1
+	}
+
+	series := make([]Series, 0, len(measurements))
+	for _, measurement := range measurements {
+		measurementSeries, err := queryMeasurement(db, measurement, from, to, width)
+		if err != nil {
+			return nil, err
+		}
+
+		if measurementSeries.Count == 0 {
+			continue
+		}
+
+		measurementSeries.Session = session
+		series = append(series, measurementSeries)
+	}
+
+	return series, nil
+}
+
+func queryMeasurement(db *sql.DB, measurement string, from time.Time, to time.Time, width int64) (Series, error) {
+	rows, err := db.Query(`SELECT (timestamp - ?1) / ?2 AS bucket, MIN(value), MAX(value), AVG(value), COUNT(value)
+		FROM measurements
+		WHERE id = ?3 AND timestamp >= ?1 AND timestamp <= ?4 AND value IS NOT NULL
+		GROUP BY bucket ORDER BY bucket`, from.UnixNano(), width, measurement, to.UnixNano())
+	if err != nil {
+		return Series{}, err
+	}
+	defer rows.Close()
+
+	series := Series{Measurement: measurement, Buckets: make([]common.Bucket, 0)}
+	sum := 0.0
+	for rows.Next() {
+		var index int64
+		var bucket common.Bucket
+		if err := rows.Scan(&index, &bucket.Min, &bucket.Max, &bucket.Mean, &bucket.Count); err != nil {
+			return Series{}, err
+		}
+		bucket.Timestamp = from.Add(time.Duration(index * width))
+
+		if series.Count == 0 || bucket.Min < series.Min {
+			series.Min = bucket.Min
+		}
+		if series.Count == 0 || bucket.Max > series.Max {
+			series.Max = bucket.Max
+		}
+		sum += bucket.Mean * float64(bucket.Count)
+		series.Count += bucket.Count
+		series.Buckets = append(series.Buckets, bucket)
+	}
+
+	if series.Count > 0 {
+		series.Mean = sum / float64(series.Count)
+	}
+
+	return series, rows.Err()
+}
@@ -0,0 +1,197 @@
+package sqlite_logger
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
+	protection_logger "github.com/HyperloopUPV-H8/Backend-H8/message_logger"
+	"github.com/HyperloopUPV-H8/Backend-H8/order_logger"
+	"github.com/HyperloopUPV-H8/Backend-H8/packet"
+	"github.com/HyperloopUPV-H8/Backend-H8/packet_logger"
+	"github.com/HyperloopUPV-H8/Backend-H8/value_logger"
+	"github.com/rs/zerolog"
+	trace "github.com/rs/zerolog/log"
+	_ "modernc.org/sqlite"
+)
+
+type Config struct {
+	// Enable adds the sqlite logger to the session loggers
+	Enable   bool   `toml:"enable"`
+	FileName string `toml:"file_name"`
+	// FlushInterval commits the pending rows, a crash loses at most this much data
+	FlushInterval string `toml:"flush_interval"`
+	// Endpoint serves the series queries over HTTP
+	Endpoint string `toml:"endpoint"`
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS measurements (timestamp INTEGER NOT NULL, id TEXT NOT NULL, value REAL, text TEXT);
+CREATE INDEX IF NOT EXISTS measurements_id_timestamp ON measurements (id, timestamp);
+CREATE TABLE IF NOT EXISTS packets (timestamp INTEGER NOT NULL, id INTEGER NOT NULL, source TEXT, destination TEXT, hex_value TEXT);
+CREATE TABLE IF NOT EXISTS orders (timestamp INTEGER NOT NULL, kind TEXT NOT NULL, source TEXT, destination TEXT, seq_num TEXT, id TEXT, fields TEXT);
+CREATE TABLE IF NOT EXISTS protections (timestamp INTEGER NOT NULL, kind TEXT NOT NULL, board TEXT, name TEXT, protection TEXT, data TEXT, board_timestamp TEXT);
+`
+
+// SQLiteLogger writes the packets, values, orders and protections of a session into one SQLite database,
+// timestamps are stored as unix nanoseconds
+type SQLiteLogger struct {
+	ids           common.Set[string]
+	fileName      string
+	flushInterval time.Duration
+	trace         zerolog.Logger
+}
+
+// NewSQLiteLogger logs every loggable whose id is in ids, usually the union of the other loggers ids
+func NewSQLiteLogger(ids common.Set[string], config Config) SQLiteLogger {
+	trace := trace.With().Str("component", "sqliteLogger").Logger()
+
+	flushInterval, err := time.ParseDuration(config.FlushInterval)
+
+	if err != nil {
+		trace.Fatal().Err(err).Str("flushInterval", config.FlushInterval).Msg("error parsing flush duration")
+	}
+
+	return SQLiteLogger{
+		ids:           ids,
+		fileName:      config.FileName,
+		flushInterval: flushInterval,
+		trace:         trace,
+	}
+}
+
+func (sl *SQLiteLogger) Ids() common.Set[string] {
+	return sl.ids
+}
+
+func (sl *SQLiteLogger) Start(basePath string) chan<- logger_handler.Loggable {
+	loggableChan := make(chan logger_handler.Loggable)
+
+	go sl.startLoggingRoutine(loggableChan, filepath.Join(basePath, sl.fileName))
+
+	return loggableChan
+}
+
+func openDatabase(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)", path))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	os.Chmod(path, 0777)
+
+	return db, nil
+}
+
+// startLoggingRoutine writes every row inside a transaction committed on each flush tick
+func (sl *SQLiteLogger) startLoggingRoutine(loggableChan <-chan logger_handler.Loggable, path string) {
+	db, err := openDatabase(path)
+	if err != nil {
+		sl.trace.Error().Err(err).Str("path", path).Msg("error opening database")
+		for range loggableChan {
+		}
+		return
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		sl.trace.Error().Err(err).Msg("error starting transaction")
+	}
+
+	flushTicker := time.NewTicker(sl.flushInterval)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case loggable, ok := <-loggableChan:
+			if !ok {
+				sl.commit(tx)
+				return
+			}
+
+			if tx == nil {
+				continue
+			}
+
+			if err := insert(tx, loggable); err != nil {
+				sl.trace.Error().Err(err).Str("id", loggable.Id()).Msg("error inserting row")
+			}
+		case <-flushTicker.C:
+			sl.commit(tx)
+			tx, err = db.Begin()
+			if err != nil {
+				sl.trace.Error().Err(err).Msg("error starting transaction")
+			}
+		}
+	}
+}
+
+func (sl *SQLiteLogger) commit(tx *sql.Tx) {
+	if tx == nil {
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		sl.trace.Error().Err(err).Msg("error committing transaction")
+	}
+}
+
+func insert(tx *sql.Tx, loggable logger_handler.Loggable) error {
+	switch loggable := loggable.(type) {
+	case value_logger.LoggableValue:
+		value, text := toColumns(loggable.Value)
+		_, err := tx.Exec("INSERT INTO measurements VALUES (?, ?, ?, ?)", loggable.Timestamp.UnixNano(), loggable.ValueId, value, text)
+		return err
+	case packet_logger.LoggablePacket:
+		row := loggable.Log()
+		_, err := tx.Exec("INSERT INTO packets VALUES (?, ?, ?, ?, ?)", loggable.Metadata.Timestamp.UnixNano(), loggable.Metadata.ID, row[1], row[2], row[4])
+		return err
+	case order_logger.LoggableOrder, order_logger.LoggableTransmittedOrder, order_logger.LoggableEmergencyStop, order_logger.LoggableStateOrderEvent:
+		// the rows follow order_logger.Header
+		row := loggable.Log()
+		timestamp, err := time.Parse(logger_handler.TimestampFormat, row[1])
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO orders VALUES (?, ?, ?, ?, ?, ?, ?)", timestamp.UnixNano(), row[0], row[2], row[3], row[4], row[5], row[6])
+		return err
+	case protection_logger.LoggableProtection:
+		return insertProtection(tx, loggable.ReceivedAt, loggable.Log())
+	case protection_logger.LoggableInfo:
+		return insertProtection(tx, loggable.ReceivedAt, loggable.Log())
+	default:
+		return fmt.Errorf("unsupported loggable %T", loggable)
+	}
+}
+
+// insertProtection stores the time the message was received, the row follows protection_logger.Header
+// and its board timestamp, which has no time zone, is kept as text
+func insertProtection(tx *sql.Tx, receivedAt time.Time, row []string) error {
+	_, err := tx.Exec("INSERT INTO protections VALUES (?, ?, ?, ?, ?, ?, ?)", receivedAt.UnixNano(), row[1], row[2], row[3], row[4], row[5], row[6])
+	return err
+}
+
+// toColumns stores numbers and booleans in the value column so they can be aggregated, enums go to the text column
+func toColumns(value packet.Value) (any, any) {
+	switch inner := value.Inner().(type) {
+	case float64:
+		return inner, nil
+	case bool:
+		if inner {
+			return 1.0, "true"
+		}
+		return 0.0, "false"
+	default:
+		return nil, fmt.Sprint(inner)
+	}
+}
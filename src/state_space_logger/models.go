@@ -4,6 +4,14 @@ import "fmt"
 
 type LoggableStateSpaceRow [15]float32
 
+func Header() []string {
+	header := make([]string, len(LoggableStateSpaceRow{}))
+	for index := range header {
+		header[index] = fmt.Sprintf("state_%d", index)
+	}
+	return header
+}
+
 func (lo LoggableStateSpaceRow) Id() string {
 	return "7"
 }
@@ -10,7 +10,7 @@ import (
 func NewStateSpaceLogger(stateSpaceId uint16) file_logger.FileLogger {
 	ids := common.NewSet[string]()
 	ids.Add(strconv.Itoa(int(stateSpaceId)))
-	return file_logger.NewFileLogger("stateSpaceLogger", ids, file_logger.Config{
+	return file_logger.NewFileLogger("stateSpaceLogger", ids, Header(), file_logger.Config{
 		FileName:      "stateSpace",
 		FlushInterval: "3s",
 	})
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/HyperloopUPV-H8/Backend-H8/logger_handler"
 	"github.com/HyperloopUPV-H8/Backend-H8/packet"
 )
 
@@ -19,8 +20,8 @@ func (value LoggableValue) Id() string {
 
 func (value LoggableValue) Log() []string {
 	return []string{
-		value.Timestamp.String(),
-		fmt.Sprintf("%v", value.Value),
+		logger_handler.FormatTimestamp(value.Timestamp),
+		fmt.Sprint(value.Value.Inner()),
 	}
 }
 
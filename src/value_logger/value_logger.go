@@ -1,6 +1,7 @@
 package value_logger
 
 import (
+	"fmt"
 	"path/filepath"
 	"sync"
 	"time"
@@ -14,9 +15,11 @@ import (
 
 type ValueLogger struct {
 	ids           common.Set[string]
+	headers       map[string][]string
 	filesMx       *sync.Mutex
 	flushInterval time.Duration
 	folderName    string
+	csvOptions    logger_handler.CSVOptions
 	trace         zerolog.Logger
 }
 
@@ -29,6 +32,7 @@ func NewValueLogger(boards []pod_data.Board, config Config) ValueLogger {
 	trace := trace.With().Str("component", "valueLogger").Logger()
 
 	ids := getValueIds(boards)
+	headers := getHeaders(boards)
 
 	flushInterval, err := time.ParseDuration(config.FlushInterval)
 
@@ -38,6 +42,7 @@ func NewValueLogger(boards []pod_data.Board, config Config) ValueLogger {
 
 	return ValueLogger{
 		ids:           ids,
+		headers:       headers,
 		folderName:    config.FolderName,
 		filesMx:       &sync.Mutex{},
 		flushInterval: flushInterval,
@@ -59,6 +64,29 @@ func getValueIds(boards []pod_data.Board) common.Set[string] {
 	return ids
 }
 
+// getHeaders names the value column of each measurement with its units
+func getHeaders(boards []pod_data.Board) map[string][]string {
+	headers := make(map[string][]string)
+
+	for _, board := range boards {
+		for _, packet := range board.Packets {
+			for _, meas := range packet.Measurements {
+				column := meas.GetId()
+				if numeric, ok := meas.(pod_data.NumericMeasurement); ok && numeric.Units != "" {
+					column = fmt.Sprintf("%s (%s)", column, numeric.Units)
+				}
+				headers[meas.GetId()] = []string{"timestamp", column}
+			}
+		}
+	}
+
+	return headers
+}
+
+func (vl *ValueLogger) SetCSVOptions(options logger_handler.CSVOptions) {
+	vl.csvOptions = options
+}
+
 func (vl *ValueLogger) Ids() common.Set[string] {
 	return vl.ids
 }
@@ -72,7 +100,7 @@ func (vl *ValueLogger) Start(basePath string) chan<- logger_handler.Loggable {
 }
 
 func (vl *ValueLogger) startLoggingRoutine(loggableChan <-chan logger_handler.Loggable, basePath string) {
-	files := make(map[string]logger_handler.CSVFile)
+	files := make(map[string]*logger_handler.CSVFile)
 	flushTicker := time.NewTicker(vl.flushInterval)
 	done := make(chan struct{})
 
@@ -80,9 +108,15 @@ func (vl *ValueLogger) startLoggingRoutine(loggableChan <-chan logger_handler.Lo
 
 	for loggable := range loggableChan {
 		vl.filesMx.Lock()
-		file := getOrAddFile(files, filepath.Join(basePath, vl.folderName), loggable.Id(), vl.trace)
-		file.Write(loggable.Log())
+		file, err := getOrAddFile(files, filepath.Join(basePath, vl.folderName), loggable.Id(), vl.headers[loggable.Id()], vl.csvOptions)
+		if err == nil {
+			file.Write(loggable.Log())
+		}
 		vl.filesMx.Unlock()
+
+		if err != nil {
+			vl.trace.Error().Err(err).Str("id", loggable.Id()).Msg("error creating file")
+		}
 	}
 
 	done <- struct{}{}
@@ -91,22 +125,23 @@ func (vl *ValueLogger) startLoggingRoutine(loggableChan <-chan logger_handler.Lo
 	closeFiles(files, vl.trace)
 }
 
-func getOrAddFile(files map[string]logger_handler.CSVFile, path string, name string, trace zerolog.Logger) logger_handler.CSVFile {
+// getOrAddFile fails if the file can't be created, for example with the disk full, instead of stopping the backend
+func getOrAddFile(files map[string]*logger_handler.CSVFile, path string, name string, header []string, options logger_handler.CSVOptions) (*logger_handler.CSVFile, error) {
 	file, ok := files[name]
 	if !ok {
-		newFile, err := logger_handler.NewCSVFile(path, name)
+		newFile, err := logger_handler.NewCSVFile(path, name, header, options)
 
 		if err != nil {
-			trace.Fatal().Err(err).Msg("error creating file")
+			return nil, err
 		}
 		files[name] = newFile
-		return newFile
+		return newFile, nil
 	}
 
-	return file
+	return file, nil
 }
 
-func (vl *ValueLogger) startFlushRoutine(tickerChan <-chan time.Time, files map[string]logger_handler.CSVFile, done chan struct{}) {
+func (vl *ValueLogger) startFlushRoutine(tickerChan <-chan time.Time, files map[string]*logger_handler.CSVFile, done chan struct{}) {
 loop:
 	for {
 		select {
@@ -122,7 +157,7 @@ loop:
 	}
 }
 
-func closeFiles(files map[string]logger_handler.CSVFile, trace zerolog.Logger) {
+func closeFiles(files map[string]*logger_handler.CSVFile, trace zerolog.Logger) {
 	for _, file := range files {
 		err := file.Close()
 
@@ -0,0 +1,118 @@
+package vehicle
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/blcu"
+	"github.com/HyperloopUPV-H8/Backend-H8/blcu/blcutest"
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/HyperloopUPV-H8/Backend-H8/info"
+	"github.com/HyperloopUPV-H8/Backend-H8/packet"
+	"github.com/HyperloopUPV-H8/Backend-H8/pipe"
+	"github.com/HyperloopUPV-H8/Backend-H8/pod_data"
+	"github.com/HyperloopUPV-H8/Backend-H8/unit_converter"
+	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/packet_parser"
+	"github.com/rs/zerolog"
+)
+
+// TestBLCUAck runs the transfers against the fake BLCU through a backend pipe, so the acks
+// are read by the pipe readers and routed by Listen like the ones from the real board
+func TestBLCUAck(t *testing.T) {
+	const ackId = 3
+
+	boardIds := map[string]uint16{"VCU": 1, "BMSL": 2}
+	config := blcu.BLCUConfig{AckTimeout: "500ms", TFTPTimeout: "500ms", TFTPRetries: 1}
+	config.Packets.Upload = blcu.PacketData{Id: 700, Field: "write_board"}
+	config.Packets.Download = blcu.PacketData{Id: 701, Field: "read_board"}
+
+	server, err := blcutest.NewServer(boardIds, config)
+	if err != nil {
+		t.Fatalf("starting test server: %s", err)
+	}
+	t.Cleanup(server.Close)
+
+	fakePipe, err := server.ListenPipe("127.0.0.1:0", ackId)
+	if err != nil {
+		t.Fatalf("listening pipe: %s", err)
+	}
+	t.Cleanup(func() { fakePipe.Close() })
+
+	boards := []pod_data.Board{{Name: "BLCU", Packets: []pod_data.Packet{
+		{Id: 700, Type: "order", Measurements: []pod_data.Measurement{pod_data.NumericMeasurement{Id: "write_board", Type: "uint16"}}},
+		{Id: 701, Type: "order", Measurements: []pod_data.Measurement{pod_data.NumericMeasurement{Id: "read_board", Type: "uint16"}}},
+	}}}
+
+	vehicleInfo := info.Info{
+		Addresses:  info.Addresses{Backend: net.IPv4(127, 0, 0, 1), Boards: map[string]net.IP{"BLCU": net.IPv4(127, 0, 0, 1)}},
+		Ports:      info.Ports{TcpServer: uint16(fakePipe.Addr().Port)},
+		MessageIds: info.MessageIds{BlcuAck: ackId},
+	}
+
+	packetParser, err := packet_parser.CreatePacketParser(vehicleInfo, boards, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("creating packet parser: %s", err)
+	}
+
+	messageIds := common.NewSet[uint16]()
+	messageIds.Add(ackId)
+
+	dataChan := make(chan packet.Packet, UPDATE_CHAN_BUF_SIZE)
+	vehicle := Vehicle{
+		podConverter:     unit_converter.NewUnitConverter("pod", boards, nil),
+		displayConverter: unit_converter.NewUnitConverter("display", boards, nil),
+		backendAddr:      vehicleInfo.Addresses.Backend,
+		pipes:            pipe.CreatePipes(vehicleInfo, nil, nil, nil, dataChan, func(string, bool) {}, pipe.Config{}, newPipeReaders(vehicleInfo.MessageIds), zerolog.Nop()),
+		dataIds:          common.NewSet[uint16](),
+		orderIds:         common.NewSet[uint16](),
+		messageIds:       messageIds,
+		blcuAckId:        ackId,
+		packetParser:     packetParser,
+		bitarrayParser:   NewBitarrayParser(map[uint16][]string{}),
+		dataChan:         dataChan,
+		idToBoard:        map[uint16]string{700: "BLCU", 701: "BLCU"},
+		trace:            zerolog.Nop(),
+	}
+
+	board := blcu.NewBLCU(server.Addr(), boardIds, config)
+	board.SetSendOrder(vehicle.SendOrder)
+
+	ackChan := make(chan struct{})
+	go vehicle.Listen(nil, nil, nil, ackChan, nil, nil)
+	go func() {
+		for range ackChan {
+			board.NotifyAck()
+		}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !vehicle.pipes["BLCU"].IsConnected() {
+		if time.Now().After(deadline) {
+			t.Fatalf("backend pipe did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	image := bytes.Repeat([]byte{0xCA, 0xFE}, 3000)
+	server.SetMemory("BMSL", image)
+
+	request := httptest.NewRequest(http.MethodGet, "/blcu/download?board=BMSL", nil)
+	recorder := httptest.NewRecorder()
+	board.Handlers("/blcu")["/blcu/download"].ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK || !bytes.HasPrefix(recorder.Body.Bytes(), image) {
+		t.Fatalf("expected the board image, got status %d and %d bytes", recorder.Code, recorder.Body.Len())
+	}
+
+	server.DropAcks(true)
+	recorder = httptest.NewRecorder()
+	board.Handlers("/blcu")["/blcu/download"].ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/blcu/download?board=BMSL", nil))
+
+	if recorder.Code != http.StatusBadGateway {
+		t.Fatalf("expected status %d without ack, got %d", http.StatusBadGateway, recorder.Code)
+	}
+}
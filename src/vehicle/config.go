@@ -12,6 +12,18 @@ type Config struct {
 	Network      NetworkConfig        `toml:"network"`
 	PacketParser packet_parser.Config `toml:"packet_parser"`
 	Messages     MessageConfig        `toml:"messages"`
+	// OrderForwarding maps boards without a pipe to the board that relays their orders
+	OrderForwarding map[string]string `toml:"order_forwarding,omitempty"`
+	Emergency       EmergencyConfig   `toml:"emergency"`
+}
+
+type EmergencyConfig struct {
+	// OnFault sends the emergency order as soon as a fault is received from any board
+	OnFault bool `toml:"on_fault"`
+	// PropagateFaults forwards the raw fault message to the rest of the boards
+	PropagateFaults bool     `toml:"propagate_faults"`
+	OrderId         uint16   `toml:"order_id"`
+	Boards          []string `toml:"boards"`
 }
 
 type NetworkConfig struct {
@@ -9,6 +9,7 @@ import (
 	"github.com/HyperloopUPV-H8/Backend-H8/sniffer"
 	"github.com/HyperloopUPV-H8/Backend-H8/unit_converter"
 	protection_parser "github.com/HyperloopUPV-H8/Backend-H8/vehicle/message_parser"
+	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
 	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/packet_parser"
 	"github.com/rs/zerolog"
 	trace "github.com/rs/zerolog/log"
@@ -24,6 +25,7 @@ type VehicleConstructorArgs struct {
 	PodData            pod_data.PodData
 	Config             Config
 	OnConnectionChange func(string, bool)
+	OnEmergencyStop    func(models.EmergencyStop)
 }
 
 func New(args VehicleConstructorArgs) Vehicle {
@@ -67,6 +69,7 @@ func New(args VehicleConstructorArgs) Vehicle {
 		orderIds:            getBoardIdsFromType(args.Boards, "order", vehicleTrace),
 		messageIds:          messageIds,
 		blcuAckId:           args.Info.MessageIds.BlcuAck,
+		faultId:             args.Info.MessageIds.Fault,
 		addStateOrdersId:    args.Info.MessageIds.AddStateOrder,
 		removeStateOrdersId: args.Info.MessageIds.RemoveStateOrder,
 		stateSpaceId:        args.Info.MessageIds.StateSpace,
@@ -78,6 +81,9 @@ func New(args VehicleConstructorArgs) Vehicle {
 		dataChan: dataChan,
 
 		idToBoard:          getIdToBoard(args.Boards, vehicleTrace),
+		orderForwarding:    getOrderForwarding(args.Config.OrderForwarding),
+		emergency:          args.Config.Emergency,
+		onEmergencyStop:    getOnEmergencyStop(args.OnEmergencyStop),
 		onConnectionChange: args.OnConnectionChange,
 		trace:              vehicleTrace,
 	}
@@ -135,6 +141,20 @@ func getIdToBoard(boards []pod_data.Board, trace zerolog.Logger) map[uint16]stri
 	return idToBoard
 }
 
+func getOrderForwarding(forwarding map[string]string) map[string]string {
+	if forwarding == nil {
+		return make(map[string]string)
+	}
+	return forwarding
+}
+
+func getOnEmergencyStop(onEmergencyStop func(models.EmergencyStop)) func(models.EmergencyStop) {
+	if onEmergencyStop == nil {
+		return func(models.EmergencyStop) {}
+	}
+	return onEmergencyStop
+}
+
 func getBoardIdsFromType(boards []pod_data.Board, kind string, trace zerolog.Logger) common.Set[uint16] {
 	ids := common.NewSet[uint16]()
 
@@ -0,0 +1,56 @@
+package vehicle
+
+import (
+	"time"
+
+	"github.com/HyperloopUPV-H8/Backend-H8/common"
+	"github.com/HyperloopUPV-H8/Backend-H8/vehicle/models"
+)
+
+// EmergencyStop sends the configured emergency order straight to the pipe of every emergency board,
+// skipping the usual order routing so a missing board can't prevent the rest from stopping
+func (vehicle *Vehicle) EmergencyStop(source string, reason string) models.EmergencyStop {
+	vehicle.trace.Warn().Str("source", source).Str("reason", reason).Msg("emergency stop")
+
+	record := models.EmergencyStop{
+		Source:    source,
+		Reason:    reason,
+		Timestamp: time.Now(),
+		Results:   make(map[string]string, len(vehicle.emergency.Boards)),
+	}
+
+	buf, err := vehicle.orderToBuf(models.Order{ID: vehicle.emergency.OrderId, Fields: map[string]models.Field{}})
+
+	for _, board := range vehicle.emergency.Boards {
+		if err != nil {
+			record.Results[board] = err.Error()
+			continue
+		}
+
+		pipe, ok := vehicle.pipes[board]
+		if !ok || !pipe.IsConnected() {
+			record.Results[board] = "disconnected"
+			continue
+		}
+
+		if _, writeErr := common.WriteAll(pipe, buf); writeErr != nil {
+			vehicle.trace.Error().Err(writeErr).Str("board", board).Msg("sending emergency stop")
+			record.Results[board] = writeErr.Error()
+			continue
+		}
+
+		record.Results[board] = models.EmergencyStopSent
+	}
+
+	vehicle.onEmergencyStop(record)
+
+	return record
+}
+
+func (vehicle *Vehicle) propagateFault(source string, payload []byte) {
+	for _, pipe := range vehicle.pipes {
+		if pipe.IsConnected() {
+			pipe.SendFault(source, vehicle.faultId, payload)
+		}
+	}
+}
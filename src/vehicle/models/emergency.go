@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type EmergencyStop struct {
+	Source    string            `json:"source"`
+	Reason    string            `json:"reason"`
+	Timestamp time.Time         `json:"timestamp"`
+	Results   map[string]string `json:"results"`
+}
+
+const EmergencyStopSent = "sent"
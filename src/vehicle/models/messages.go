@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type StateOrdersMessage struct {
 	BoardId string   `json:"board"`
 	Orders  []uint16 `json:"orders"`
@@ -21,6 +23,19 @@ type ProtectionMessage struct {
 	Protection Protection `json:"protection"`
 }
 
+const BackendBoard = "BACKEND"
+
+// NewBackendWarning creates a warning raised by the backend itself, shown in the GUI like the board warnings
+func NewBackendWarning(name string, msg string) ProtectionMessage {
+	return ProtectionMessage{
+		Board:      BackendBoard,
+		Name:       name,
+		Timestamp:  NewTimestamp(time.Now()),
+		Kind:       "warning",
+		Protection: Protection{Kind: "ERROR_HANDLER", Data: Error(msg)},
+	}
+}
+
 type Protection struct {
 	Kind string `json:"kind"`
 	Data any    `json:"data"`
@@ -49,3 +64,16 @@ type NotEquals struct {
 type Error = string
 
 type Info = string
+
+const (
+	AddStateOrdersAction    = "add"
+	RemoveStateOrdersAction = "remove"
+	ClearStateOrdersAction  = "clear"
+)
+
+type StateOrderEvent struct {
+	Board     string    `json:"board"`
+	Action    string    `json:"action"`
+	Orders    []uint16  `json:"orders"`
+	Timestamp time.Time `json:"timestamp"`
+}
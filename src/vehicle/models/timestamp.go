@@ -1,5 +1,10 @@
 package models
 
+import (
+	"fmt"
+	"time"
+)
+
 type Timestamp struct {
 	Counter uint16 `json:"counter"`
 	Second  uint8  `json:"second"`
@@ -9,3 +14,21 @@ type Timestamp struct {
 	Month   uint8  `json:"month"`
 	Year    uint16 `json:"year"`
 }
+
+// String writes the timestamp in ISO 8601 with second precision, boards don't send a time zone
+func (timestamp Timestamp) String() string {
+	return fmt.Sprintf("%04d-%02d-%02dT%02d:%02d:%02d", timestamp.Year, timestamp.Month, timestamp.Day, timestamp.Hour, timestamp.Minute, timestamp.Second)
+}
+
+// NewTimestamp converts a local time to the format used by the boards
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{
+		Counter: 0,
+		Second:  uint8(t.Second()),
+		Minute:  uint8(t.Minute()),
+		Hour:    uint8(t.Hour()),
+		Day:     uint8(t.Day()),
+		Month:   uint8(t.Month()),
+		Year:    uint16(t.Year()),
+	}
+}
@@ -30,6 +30,7 @@ type Vehicle struct {
 	orderIds            common.Set[uint16]
 	messageIds          common.Set[uint16]
 	blcuAckId           uint16
+	faultId             uint16
 	addStateOrdersId    uint16
 	removeStateOrdersId uint16
 	stateSpaceId        uint16
@@ -40,19 +41,17 @@ type Vehicle struct {
 
 	dataChan chan packet.Packet
 
-	idToBoard map[uint16]string
+	idToBoard       map[uint16]string
+	orderForwarding map[string]string
+
+	emergency       EmergencyConfig
+	onEmergencyStop func(models.EmergencyStop)
 
 	onConnectionChange func(string, bool)
 
 	trace zerolog.Logger
 }
 
-// func (vehicle *Vehicle) propagateFault(source string, payload []byte) {
-// 	for _, pipe := range vehicle.pipes {
-// 		pipe.SendFault(source, payload)
-// 	}
-// }
-
 func (vehicle *Vehicle) Listen(updateChan chan<- models.PacketUpdate, transmittedOrderChan chan<- models.PacketUpdate, messageChan chan<- any, blcuAckChan chan<- struct{}, stateOrdersChan chan<- message_parser.StateOrdersAdapter, stateSpaceChan chan<- models.StateSpace) {
 	vehicle.trace.Debug().Msg("vehicle listening")
 	for packet := range vehicle.dataChan {
@@ -107,6 +106,10 @@ func (vehicle *Vehicle) Listen(updateChan chan<- models.PacketUpdate, transmitte
 				continue
 			}
 
+			if id == vehicle.faultId {
+				vehicle.handleFault(packet.Metadata.From, payloadCopy, message)
+			}
+
 			if id == vehicle.addStateOrdersId || id == vehicle.removeStateOrdersId {
 				stateOrders, ok := message.(message_parser.StateOrdersAdapter)
 				if !ok {
@@ -125,31 +128,31 @@ func (vehicle *Vehicle) Listen(updateChan chan<- models.PacketUpdate, transmitte
 	}
 }
 
-func (vehicle *Vehicle) SendOrder(order models.Order) error {
-	vehicle.trace.Info().Uint16("id", order.ID).Msg("send order")
-
-	board, ok := vehicle.idToBoard[order.ID]
+func (vehicle *Vehicle) handleFault(from string, payload []byte, message any) {
+	if vehicle.emergency.PropagateFaults {
+		vehicle.propagateFault(from, payload)
+	}
 
-	if !ok {
-		return fmt.Errorf("board for order id %d not found", order.ID)
+	if !vehicle.emergency.OnFault {
+		return
 	}
 
-	switch board {
-	case "BLCU":
-		return vehicle.sendOrderToBoard(order, "BLCU")
-	case "TCU":
-		return vehicle.sendOrderToBoard(order, "TCU")
-	default:
-		return vehicle.sendOrderToBoard(order, "VCU")
+	board, reason := from, "fault"
+	if protection, ok := message.(models.ProtectionMessage); ok {
+		board, reason = protection.Board, fmt.Sprintf("fault %s", protection.Name)
 	}
+
+	vehicle.EmergencyStop(board, reason)
 }
 
-func (vehicle *Vehicle) sendOrderToBoard(order models.Order, board string) error {
-	pipe, ok := vehicle.pipes[board]
+func (vehicle *Vehicle) SendOrder(order models.Order) error {
+	vehicle.trace.Info().Uint16("id", order.ID).Msg("send order")
 
-	if !ok {
-		vehicle.trace.Error().Str("board", board).Msg("pipe not found")
-		return nil
+	pipe, err := vehicle.getPipe(order.ID)
+
+	if err != nil {
+		vehicle.trace.Error().Err(err).Uint16("id", order.ID).Msg("getting order pipe")
+		return err
 	}
 
 	buf, err := vehicle.orderToBuf(order)
@@ -237,14 +240,38 @@ func (vehicle *Vehicle) getPipe(id uint16) (*pipe.Pipe, error) {
 		return nil, fmt.Errorf("board for id %d not found", id)
 	}
 
-	pipe, ok := vehicle.pipes[board]
-	if !ok {
-		return nil, fmt.Errorf("pipe for board %s not found", board)
+	target, err := vehicle.getTargetBoard(board)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := vehicle.pipes[target]
+	if !pipe.IsConnected() {
+		return nil, fmt.Errorf("pipe for board %s is disconnected", target)
 	}
 
 	return pipe, nil
 }
 
+// getTargetBoard returns the board whose pipe carries the orders of board, which
+// is the board itself when it has a pipe or the one set in the forwarding rules
+func (vehicle *Vehicle) getTargetBoard(board string) (string, error) {
+	if _, ok := vehicle.pipes[board]; ok {
+		return board, nil
+	}
+
+	target, ok := vehicle.orderForwarding[board]
+	if !ok {
+		return "", fmt.Errorf("pipe for board %s not found", board)
+	}
+
+	if _, ok := vehicle.pipes[target]; !ok {
+		return "", fmt.Errorf("pipe for board %s (forwarding %s) not found", target, board)
+	}
+
+	return target, nil
+}
+
 func getOrderValues(order models.Order, trace zerolog.Logger) map[string]packet.Value {
 	values := make(map[string]packet.Value)
 
@@ -7,8 +7,15 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+const (
+	OperatorRole = "operator"
+	ReadOnlyRole = "read_only"
+)
+
 type Client struct {
-	id string
+	id       string
+	role     string
+	encoding Encoding
 
 	conn    *websocket.Conn //FIXME: why pointer?
 	readMx  *sync.Mutex
@@ -19,11 +26,25 @@ func (c *Client) Id() string {
 	return c.id
 }
 
-func (c *Client) Read() ([]byte, error) {
+func (c *Client) Role() string {
+	return c.role
+}
+
+// IsReadOnly reports whether the client is only allowed to receive data, not to command the vehicle.
+// Only operators can send commands, so unknown roles are read only too
+func (c *Client) IsReadOnly() bool {
+	return c.role != OperatorRole
+}
+
+func (c *Client) Encoding() Encoding {
+	return c.encoding
+}
+
+// Read returns the websocket message type along with the data, binary messages use the client encoding
+func (c *Client) Read() (int, []byte, error) {
 	c.readMx.Lock()
 	defer c.readMx.Unlock()
-	_, p, err := c.conn.ReadMessage()
-	return p, err
+	return c.conn.ReadMessage()
 }
 
 func (c *Client) Write(b []byte) error {
@@ -32,6 +53,18 @@ func (c *Client) Write(b []byte) error {
 	return c.conn.WriteMessage(websocket.TextMessage, b)
 }
 
+// WriteMessage encodes the message with the encoding negotiated by the client
+func (c *Client) WriteMessage(topic string, v any) error {
+	messageType, data, err := c.encoding.Marshal(topic, v)
+	if err != nil {
+		return err
+	}
+
+	c.writeMx.Lock()
+	defer c.writeMx.Unlock()
+	return c.conn.WriteMessage(messageType, data)
+}
+
 func (c *Client) Ping() error {
 	c.writeMx.Lock()
 	defer c.writeMx.Unlock()
@@ -42,9 +75,20 @@ func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
-func NewClient(conn *websocket.Conn) Client {
+// IsValidRole reports whether role is one of the known client roles
+func IsValidRole(role string) bool {
+	return role == OperatorRole || role == ReadOnlyRole
+}
+
+func NewClient(conn *websocket.Conn, role string) Client {
+	if role == "" {
+		role = OperatorRole
+	}
+
 	return Client{
-		id: uuid.NewString(), //TODO: CAN PANIC
+		id:       uuid.NewString(), //TODO: CAN PANIC
+		role:     role,
+		encoding: NewEncoding(conn.Subprotocol()),
 
 		conn:    conn,
 		writeMx: &sync.Mutex{},
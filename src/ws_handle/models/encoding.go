@@ -0,0 +1,91 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Encoding is negotiated as the websocket subprotocol, clients that don't ask for one use JSON.
+// The binary encodings follow the json tags, but map keys keep their type instead of becoming strings
+type Encoding string
+
+const (
+	JSONEncoding    Encoding = "json"
+	MsgpackEncoding Encoding = "msgpack"
+	CBOREncoding    Encoding = "cbor"
+)
+
+// Subprotocols are offered in order of preference
+var Subprotocols = []string{string(MsgpackEncoding), string(CBOREncoding), string(JSONEncoding)}
+
+// wireMessage is the Message sent with the binary encodings, the payload is encoded in place instead of as raw JSON
+type wireMessage struct {
+	Topic   string `json:"topic" cbor:"topic"`
+	Payload any    `json:"payload" cbor:"payload"`
+}
+
+var cborEncoder, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+
+// cborDecoder decodes maps with string keys so the payload can be converted to JSON
+var cborDecoder, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]any{})}.DecMode()
+
+func NewEncoding(subprotocol string) Encoding {
+	switch Encoding(subprotocol) {
+	case MsgpackEncoding, CBOREncoding:
+		return Encoding(subprotocol)
+	default:
+		return JSONEncoding
+	}
+}
+
+// Marshal returns the websocket message type and the encoded message
+func (encoding Encoding) Marshal(topic string, v any) (int, []byte, error) {
+	switch encoding {
+	case MsgpackEncoding:
+		var buf bytes.Buffer
+		encoder := msgpack.NewEncoder(&buf)
+		encoder.SetCustomStructTag("json")
+		err := encoder.Encode(wireMessage{Topic: topic, Payload: v})
+		return websocket.BinaryMessage, buf.Bytes(), err
+	case CBOREncoding:
+		data, err := cborEncoder.Marshal(wireMessage{Topic: topic, Payload: v})
+		return websocket.BinaryMessage, data, err
+	default:
+		data, err := NewMessageBuf(topic, v)
+		return websocket.TextMessage, data, err
+	}
+}
+
+// Unmarshal decodes a message from the client, binary payloads are converted to JSON so handlers don't depend on the encoding
+func (encoding Encoding) Unmarshal(messageType int, data []byte) (Message, error) {
+	if messageType != websocket.BinaryMessage {
+		var msg Message
+		err := json.Unmarshal(data, &msg)
+		return msg, err
+	}
+
+	var wire wireMessage
+	var err error
+	switch encoding {
+	case MsgpackEncoding:
+		decoder := msgpack.NewDecoder(bytes.NewReader(data))
+		decoder.SetCustomStructTag("json")
+		err = decoder.Decode(&wire)
+	case CBOREncoding:
+		err = cborDecoder.Unmarshal(data, &wire)
+	default:
+		return Message{}, fmt.Errorf("binary message from a %s client", encoding)
+	}
+	if err != nil {
+		return Message{}, err
+	}
+
+	payload, err := json.Marshal(wire.Payload)
+	return Message{Topic: wire.Topic, Payload: payload}, err
+}
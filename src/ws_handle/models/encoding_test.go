@@ -0,0 +1,96 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestEncodingRoundTrip(t *testing.T) {
+	type subscription struct {
+		Id        string   `json:"id"`
+		Subscribe bool     `json:"subscribe"`
+		Packets   []uint16 `json:"packets,omitempty"`
+	}
+
+	payloads := []struct {
+		name    string
+		payload any
+	}{
+		{"struct with json tags", subscription{Id: "a", Subscribe: true, Packets: []uint16{1, 300}}},
+		{"omitted field", subscription{Id: "b"}},
+		{"nested map", map[string]any{"board": "VCU", "values": map[string]any{"speed": 1.5, "count": 3}}},
+		{"timestamp", map[string]any{"from": time.Date(2024, 1, 1, 12, 0, 0, 500, time.UTC)}},
+		{"null payload", nil},
+	}
+
+	encodings := []struct {
+		encoding    Encoding
+		messageType int
+	}{
+		{JSONEncoding, websocket.TextMessage},
+		{MsgpackEncoding, websocket.BinaryMessage},
+		{CBOREncoding, websocket.BinaryMessage},
+	}
+
+	for _, encoding := range encodings {
+		for _, test := range payloads {
+			t.Run(string(encoding.encoding)+" "+test.name, func(t *testing.T) {
+				messageType, data, err := encoding.encoding.Marshal("topic", test.payload)
+				if err != nil {
+					t.Fatalf("marshalling: %s", err)
+				}
+
+				if messageType != encoding.messageType {
+					t.Fatalf("expected message type %d, got %d", encoding.messageType, messageType)
+				}
+
+				msg, err := encoding.encoding.Unmarshal(messageType, data)
+				if err != nil {
+					t.Fatalf("unmarshalling: %s", err)
+				}
+
+				if msg.Topic != "topic" {
+					t.Fatalf("expected topic %q, got %q", "topic", msg.Topic)
+				}
+
+				expected, err := json.Marshal(test.payload)
+				if err != nil {
+					t.Fatalf("marshalling expected payload: %s", err)
+				}
+
+				var want, got any
+				if err := json.Unmarshal(expected, &want); err != nil {
+					t.Fatalf("decoding expected payload: %s", err)
+				}
+				if err := json.Unmarshal(msg.Payload, &got); err != nil {
+					t.Fatalf("decoding payload %s: %s", msg.Payload, err)
+				}
+
+				if !reflect.DeepEqual(want, got) {
+					t.Fatalf("expected %s, got %s", expected, msg.Payload)
+				}
+			})
+		}
+	}
+
+	t.Run("binary message from a json client", func(t *testing.T) {
+		_, data, err := MsgpackEncoding.Marshal("topic", nil)
+		if err != nil {
+			t.Fatalf("marshalling: %s", err)
+		}
+
+		if _, err := JSONEncoding.Unmarshal(websocket.BinaryMessage, data); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	t.Run("unknown subprotocols use json", func(t *testing.T) {
+		if encoding := NewEncoding("protobuf"); encoding != JSONEncoding {
+			t.Fatalf("expected %s, got %s", JSONEncoding, encoding)
+		}
+	})
+}
@@ -1,7 +1,6 @@
 package ws_handle
 
 import (
-	"encoding/json"
 	"sync"
 	"time"
 
@@ -30,10 +29,10 @@ func New() WebSocketBroker {
 	}
 }
 
-func (broker *WebSocketBroker) Add(conn *websocket.Conn) error {
+func (broker *WebSocketBroker) Add(conn *websocket.Conn, role string) error {
 	broker.clientsMx.Lock()
 	defer broker.clientsMx.Unlock()
-	client := models.NewClient(conn)
+	client := models.NewClient(conn, role)
 	broker.clients[client.Id()] = client
 	go broker.readMessages(client)
 	go broker.ping(client)
@@ -43,7 +42,7 @@ func (broker *WebSocketBroker) Add(conn *websocket.Conn) error {
 func (broker *WebSocketBroker) readMessages(client models.Client) {
 	broker.trace.Debug().Str("id", client.Id()).Msg("read messages")
 	for {
-		b, err := client.Read()
+		messageType, b, err := client.Read()
 
 		if err != nil {
 			broker.trace.Error().Err(err).Msg("reading message")
@@ -51,8 +50,7 @@ func (broker *WebSocketBroker) readMessages(client models.Client) {
 			return
 		}
 
-		var msg models.Message
-		err = json.Unmarshal(b, &msg)
+		msg, err := client.Encoding().Unmarshal(messageType, b)
 
 		if err != nil {
 			broker.trace.Error().Err(err).Msg("unmarshaling message")